@@ -0,0 +1,447 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cloudvic-tf-plan-viz/plan"
+)
+
+// diffKind describes how a single path differs between before and after
+type diffKind string
+
+const (
+	diffAdded     diffKind = "added"
+	diffRemoved   diffKind = "removed"
+	diffChanged   diffKind = "changed"
+	diffUnchanged diffKind = "unchanged"
+)
+
+// diffNode is one path in a recursive diff of two values. Objects and lists
+// that exist on both sides (or only on one) are broken down into Children;
+// everything else is a leaf compared as a whole.
+type diffNode struct {
+	Key       string
+	Kind      diffKind
+	Before    interface{}
+	After     interface{}
+	Sensitive bool
+	Unknown   bool
+	IsList    bool
+	Children  []*diffNode
+
+	// ForcesReplacement is set for paths listed in replace_paths
+	ForcesReplacement bool
+
+	// IsJSON is set for strings holding JSON, which are diffed by their
+	// decoded structure. NoEffectiveChange marks such strings that differ
+	// as text but decode to the same value.
+	IsJSON            bool
+	NoEffectiveChange bool
+}
+
+// diffLine is a single rendered row of a diff, in the same shape as the
+// output of terraform plan: one line per attribute, with nested objects and
+// lists opening and closing their own block.
+type diffLine struct {
+	Depth     int
+	Kind      diffKind
+	Key       string
+	Before    string
+	After     string
+	Open      string
+	Close     string
+	Comment   string
+	Sensitive bool
+	Unknown   bool
+
+	// BeforeText and AfterText are the values without the quotes added to
+	// single-line strings, for the line diff of multi-line values
+	BeforeText string
+	AfterText  string
+
+	ForcesReplacement bool
+}
+
+// diffValues recursively compares before and after, using the sensitivity
+// masks to decide which parts may not be shown and after_unknown to mark the
+// parts only known after apply. A nil side means the value does not exist
+// there, the same way Terraform treats null attributes.
+func diffValues(key string, before, after, beforeSensitive, afterSensitive, afterUnknown interface{}) *diffNode {
+	node := &diffNode{Key: key, Before: before, After: after}
+
+	// Sensitive values are compared as a whole so nothing about their
+	// structure leaks into the diff
+	if isSensitive(beforeSensitive) || isSensitive(afterSensitive) {
+		node.Sensitive = true
+		node.Kind = leafDiffKind(before, after)
+		if node.Kind == diffUnchanged && !sensitiveMasksEqual(beforeSensitive, afterSensitive) {
+			node.Kind = diffChanged
+		}
+		return node
+	}
+
+	// Unknown values are left out of after, so they are compared as a whole
+	// against whatever was there before
+	if isUnknown(afterUnknown) {
+		node.Unknown = true
+		node.After = nil
+		node.Kind = diffChanged
+		if before == nil {
+			node.Kind = diffAdded
+		}
+		return node
+	}
+
+	// An object or list that is partly unknown still exists after apply,
+	// even when every known part of it is null
+	if after == nil && hasUnknown(afterUnknown) {
+		switch afterUnknown.(type) {
+		case map[string]interface{}:
+			after = map[string]interface{}{}
+		case []interface{}:
+			after = []interface{}{}
+		}
+		node.After = after
+	}
+
+	beforeMap, beforeIsMap := before.(map[string]interface{})
+	afterMap, afterIsMap := after.(map[string]interface{})
+	beforeList, beforeIsList := before.([]interface{})
+	afterList, afterIsList := after.([]interface{})
+
+	switch {
+	case (beforeIsMap || before == nil) && (afterIsMap || after == nil) && (beforeIsMap || afterIsMap):
+		unknownMap, _ := afterUnknown.(map[string]interface{})
+		for _, childKey := range sortedUnionKeys(beforeMap, afterMap, unknownMap) {
+			node.Children = append(node.Children, diffValues(childKey,
+				beforeMap[childKey], afterMap[childKey],
+				sensitiveMaskForKey(beforeSensitive, childKey), sensitiveMaskForKey(afterSensitive, childKey),
+				unknownMaskForKey(afterUnknown, childKey)))
+		}
+	case (beforeIsList || before == nil) && (afterIsList || after == nil) && (beforeIsList || afterIsList):
+		node.IsList = true
+		unknownList, _ := afterUnknown.([]interface{})
+		length := len(beforeList)
+		if len(afterList) > length {
+			length = len(afterList)
+		}
+		if len(unknownList) > length {
+			length = len(unknownList)
+		}
+		for i := 0; i < length; i++ {
+			var beforeElem, afterElem interface{}
+			if i < len(beforeList) {
+				beforeElem = beforeList[i]
+			}
+			if i < len(afterList) {
+				afterElem = afterList[i]
+			}
+			node.Children = append(node.Children, diffValues(fmt.Sprintf("%d", i),
+				beforeElem, afterElem,
+				sensitiveMaskForIndex(beforeSensitive, i), sensitiveMaskForIndex(afterSensitive, i),
+				unknownMaskForIndex(afterUnknown, i)))
+		}
+	default:
+		if beforeJSON, afterJSON, ok := decodeJSONStrings(before, after); ok {
+			decoded := diffValues(key, beforeJSON, afterJSON, nil, nil, nil)
+			node.IsJSON = true
+			node.IsList = decoded.IsList
+			node.Children = decoded.Children
+			node.Kind = decoded.Kind
+			node.NoEffectiveChange = node.Kind == diffUnchanged && before != after
+			return node
+		}
+		node.Kind = leafDiffKind(before, after)
+		return node
+	}
+
+	switch {
+	case before == nil:
+		node.Kind = diffAdded
+	case after == nil:
+		node.Kind = diffRemoved
+	default:
+		node.Kind = diffUnchanged
+		for _, child := range node.Children {
+			if child.Kind != diffUnchanged {
+				node.Kind = diffChanged
+				break
+			}
+		}
+	}
+
+	return node
+}
+
+// decodeJSONStrings decodes before and after when they are strings holding
+// JSON objects or arrays, such as IAM policies or container definitions.
+// Either side may be missing, but every side that exists must be JSON.
+func decodeJSONStrings(before, after interface{}) (interface{}, interface{}, bool) {
+	if before == nil && after == nil {
+		return nil, nil, false
+	}
+
+	beforeJSON, ok := decodeJSONString(before)
+	if !ok {
+		return nil, nil, false
+	}
+	afterJSON, ok := decodeJSONString(after)
+	if !ok {
+		return nil, nil, false
+	}
+	return beforeJSON, afterJSON, true
+}
+
+func decodeJSONString(value interface{}) (interface{}, bool) {
+	if value == nil {
+		return nil, true
+	}
+
+	s, ok := value.(string)
+	if !ok || !isJSONString(s) {
+		return nil, false
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(s), &decoded); err != nil {
+		return nil, false
+	}
+	return decoded, true
+}
+
+func leafDiffKind(before, after interface{}) diffKind {
+	switch {
+	case before == nil && after == nil:
+		return diffUnchanged
+	case before == nil:
+		return diffAdded
+	case after == nil:
+		return diffRemoved
+	case valuesEqual(before, after):
+		return diffUnchanged
+	default:
+		return diffChanged
+	}
+}
+
+func sortedUnionKeys(maps ...map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isUnknown reports whether an after_unknown mask marks the whole value it
+// describes as unknown
+func isUnknown(mask interface{}) bool {
+	unknown, ok := mask.(bool)
+	return ok && unknown
+}
+
+// unknownMaskForKey returns the part of an after_unknown mask that applies
+// to an attribute of an object
+func unknownMaskForKey(mask interface{}, key string) interface{} {
+	if maskMap, ok := mask.(map[string]interface{}); ok {
+		return maskMap[key]
+	}
+	return nil
+}
+
+// unknownMaskForIndex returns the part of an after_unknown mask that applies
+// to an element of a list, set or tuple
+func unknownMaskForIndex(mask interface{}, index int) interface{} {
+	if maskList, ok := mask.([]interface{}); ok && index < len(maskList) {
+		return maskList[index]
+	}
+	return nil
+}
+
+// changeDiffLines diffs the before and after objects of a resource change
+// and flattens the result into rows
+func changeDiffLines(change plan.Change) []diffLine {
+	_, beforeOk := change.Before.(map[string]interface{})
+	_, afterOk := change.After.(map[string]interface{})
+	if !beforeOk && !afterOk {
+		return nil
+	}
+
+	root := diffValues("", change.Before, change.After, change.BeforeSensitive, change.AfterSensitive, change.AfterUnknown)
+	for _, path := range change.ReplacePaths {
+		root.markForcesReplacement(path)
+	}
+
+	// A resource whose whole value is sensitive has no attributes to list
+	if root.Sensitive {
+		if root.Kind == diffUnchanged {
+			return nil
+		}
+		return root.lines(0, false)
+	}
+
+	return root.diffLines()
+}
+
+// markForcesReplacement flags the node at path as forcing replacement. When
+// the path reaches inside a value that is compared as a whole, such as a
+// sensitive one, the deepest node on the path is flagged instead.
+func (n *diffNode) markForcesReplacement(path []interface{}) {
+	node := n
+	for _, step := range path {
+		next := node.child(formatPathStep(step))
+		if next == nil {
+			break
+		}
+		node = next
+	}
+	if node != n {
+		node.ForcesReplacement = true
+	}
+}
+
+func (n *diffNode) child(key string) *diffNode {
+	for _, child := range n.Children {
+		if child.Key == key {
+			return child
+		}
+	}
+	return nil
+}
+
+// formatPathStep renders one step of an attribute path, which is a string
+// for object attributes and a number for list indexes
+func formatPathStep(step interface{}) string {
+	if index, ok := step.(float64); ok {
+		return strconv.FormatFloat(index, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", step)
+}
+
+// diffLines flattens the children of a diff into rows. Unchanged siblings of
+// a changed value are collapsed into a single "hidden" comment, and paths
+// that are null on both sides are left out altogether.
+func (n *diffNode) diffLines() []diffLine {
+	return n.childLines(0)
+}
+
+func (n *diffNode) childLines(depth int) []diffLine {
+	var lines []diffLine
+	hidden := 0
+
+	for _, child := range n.Children {
+		if child.NoEffectiveChange {
+			key := child.Key
+			if n.IsList {
+				key = "element"
+			}
+			lines = append(lines, diffLine{
+				Depth:   depth,
+				Kind:    diffUnchanged,
+				Comment: fmt.Sprintf("# %s: no effective change (equivalent JSON)", key),
+			})
+			continue
+		}
+		if child.Kind == diffUnchanged {
+			if child.Before != nil || child.After != nil {
+				hidden++
+			}
+			continue
+		}
+		lines = append(lines, child.lines(depth, n.IsList)...)
+	}
+
+	// Only mention hidden siblings when the parent itself was changed; for
+	// a wholly added or removed object they are just null attributes
+	if hidden > 0 && n.Kind == diffChanged {
+		noun := "attribute"
+		if n.IsList {
+			noun = "element"
+		}
+		if hidden > 1 {
+			noun += "s"
+		}
+		lines = append(lines, diffLine{
+			Depth:   depth,
+			Kind:    diffUnchanged,
+			Comment: fmt.Sprintf("# (%d unchanged %s hidden)", hidden, noun),
+		})
+	}
+
+	return lines
+}
+
+func (n *diffNode) lines(depth int, inList bool) []diffLine {
+	key := n.Key
+	if inList {
+		key = ""
+	}
+
+	if n.Children == nil || n.Sensitive {
+		line := diffLine{Depth: depth, Kind: n.Kind, Key: key, Sensitive: n.Sensitive, Unknown: n.Unknown, ForcesReplacement: n.ForcesReplacement}
+		switch {
+		case n.Sensitive:
+			line.Before = sensitivePlaceholder
+			line.After = sensitivePlaceholder
+		case n.Unknown:
+			line.Before = formatDiffValue(n.Before)
+			line.After = unknownPlaceholder
+		default:
+			line.Before = formatDiffValue(n.Before)
+			line.After = formatDiffValue(n.After)
+			line.BeforeText = formatValue(n.Before)
+			line.AfterText = formatValue(n.After)
+		}
+		return []diffLine{line}
+	}
+
+	open, close := "{", "}"
+	if n.IsList {
+		open, close = "[", "]"
+	}
+	if n.IsJSON {
+		open, close = "jsonencode("+open, close+")"
+	}
+
+	lines := []diffLine{{Depth: depth, Kind: n.Kind, Key: key, Open: open, ForcesReplacement: n.ForcesReplacement}}
+	lines = append(lines, n.childLines(depth+1)...)
+	lines = append(lines, diffLine{Depth: depth, Kind: n.Kind, Close: close})
+	return lines
+}
+
+// formatDiffValue renders a leaf value for a diff line. Single-line strings
+// are quoted so empty and whitespace-only values stay visible.
+func formatDiffValue(value interface{}) string {
+	if value == nil {
+		return "null"
+	}
+	valueStr := formatValue(value)
+	if _, ok := value.(string); ok && !strings.Contains(valueStr, "\n") {
+		return `"` + valueStr + `"`
+	}
+	return valueStr
+}
+
+// marker returns the terraform plan style symbol for a diff line
+func (l diffLine) marker() string {
+	switch l.Kind {
+	case diffAdded:
+		return "+"
+	case diffRemoved:
+		return "-"
+	case diffChanged:
+		return "~"
+	default:
+		return " "
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"cloudvic-tf-plan-viz/plan"
+)
+
+func TestDiffTextNumbers(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after interface{}
+		want          string
+	}{
+		{"fraction", 1.2, 1.4, "~ weight = 1.2 -> 1.4"},
+		{"fraction below one", 0.5, 0.7, "~ weight = 0.5 -> 0.7"},
+		{"integer", 8080.0, 9090.0, "~ weight = 8080 -> 9090"},
+		{"large integer", 1e15, 2e15, "~ weight = 1000000000000000 -> 2000000000000000"},
+		{"inside a JSON string", `{"value":0.5}`, `{"value":0.7}`, "~   value = 0.5 -> 0.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := plan.Change{
+				Actions: plan.Actions{plan.ActionUpdate},
+				Before:  map[string]interface{}{"weight": tt.before},
+				After:   map[string]interface{}{"weight": tt.after},
+			}
+			text := strings.Join(diffText(changeDiffLines(change)), "\n")
+			if !strings.Contains(text, tt.want) {
+				t.Errorf("diff does not contain %q:\n%s", tt.want, text)
+			}
+		})
+	}
+}
+
+func TestDiffTextMultilineStrings(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after interface{}
+		want          []string
+	}{
+		{
+			name:   "single line becomes multi-line",
+			before: "one",
+			after:  "one\ntwo",
+			want:   []string{"~ note =", "    one", "+   two"},
+		},
+		{
+			name:   "multi-line becomes single line",
+			before: "one\ntwo",
+			after:  "two",
+			want:   []string{"~ note =", "-   one", "    two"},
+		},
+		{
+			name:   "multi-line line changed",
+			before: "one\ntwo\nthree",
+			after:  "one\n2\nthree",
+			want:   []string{"~ note =", "    one", "-   two", "+   2", "    three"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := plan.Change{
+				Actions: plan.Actions{plan.ActionUpdate},
+				Before:  map[string]interface{}{"note": tt.before},
+				After:   map[string]interface{}{"note": tt.after},
+			}
+			got := diffText(changeDiffLines(change))
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("diff =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestDiffTextJSONStrings(t *testing.T) {
+	tests := []struct {
+		name              string
+		before, after     string
+		noEffectiveChange bool
+		want              []string
+	}{
+		{
+			name:              "keys reordered",
+			before:            `{"a":1,"b":[1,2]}`,
+			after:             `{"b":[1,2],"a":1}`,
+			noEffectiveChange: true,
+			want:              []string{"  # policy: no effective change (equivalent JSON)"},
+		},
+		{
+			name:              "whitespace changed",
+			before:            `{"a":1}`,
+			after:             "{\n  \"a\": 1\n}",
+			noEffectiveChange: true,
+			want:              []string{"  # policy: no effective change (equivalent JSON)"},
+		},
+		{
+			name:   "nested value changed",
+			before: `{"a":{"b":1,"c":"x"}}`,
+			after:  `{"a":{"c":"x","b":2}}`,
+			want: []string{
+				"~ policy = jsonencode({",
+				"~   a = {",
+				"~     b = 1 -> 2",
+				"      # (1 unchanged attribute hidden)",
+				"~   }",
+				"~ })",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := plan.Change{
+				Actions: plan.Actions{plan.ActionUpdate},
+				Before:  map[string]interface{}{"policy": tt.before},
+				After:   map[string]interface{}{"policy": tt.after},
+			}
+
+			root := diffValues("", change.Before, change.After, nil, nil, nil)
+			policy := root.Children[0]
+			if !policy.IsJSON {
+				t.Errorf("IsJSON = false, want true")
+			}
+			if policy.NoEffectiveChange != tt.noEffectiveChange {
+				t.Errorf("NoEffectiveChange = %t, want %t", policy.NoEffectiveChange, tt.noEffectiveChange)
+			}
+
+			got := diffText(changeDiffLines(change))
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("diff =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// firewallRule is one rule of a security group or firewall, broken down to a
+// single port range and a single source or destination, so that rule sets
+// are compared element by element rather than as ordered lists
+type firewallRule struct {
+	Direction string
+	Access    string
+	Protocol  string
+	Ports     string
+	Peer      string
+}
+
+// firewallRuleChange is one row of a rule set diff
+type firewallRuleChange struct {
+	Kind diffKind
+	Rule firewallRule
+}
+
+// firewallDiff compares the rules of a security group or firewall before and
+// after a change
+type firewallDiff struct {
+	Rules    []firewallRuleChange
+	Warnings []string
+}
+
+// firewallRuleReaders extract the rules of the resource types whose rule
+// sets are shown as a table
+var firewallRuleReaders = map[string]func(values map[string]interface{}) []firewallRule{
+	"aws_security_group":             awsSecurityGroupRules,
+	"aws_security_group_rule":        awsSecurityGroupRuleRules,
+	"google_compute_firewall":        googleComputeFirewallRules,
+	"azurerm_network_security_group": azurermNetworkSecurityGroupRules,
+}
+
+// openPeers are sources and destinations that match any address
+var openPeers = map[string]bool{
+	"0.0.0.0/0": true,
+	"::/0":      true,
+	"*":         true,
+	"Internet":  true,
+}
+
+// changeFirewallDiff compares the rule sets of a security group or firewall
+// change. It returns nil for other resource types and when the rules did not
+// change, so that edits to descriptions or tags do not produce a table.
+func changeFirewallDiff(change resourceEntry) *firewallDiff {
+	readRules, ok := firewallRuleReaders[change.Type]
+	if !ok {
+		return nil
+	}
+
+	// Sensitive parts are redacted and unknown parts shown as such, the
+	// same way they are in the attribute diff
+	before, _ := redactSensitive(change.Change.Before, change.Change.BeforeSensitive).(map[string]interface{})
+	after, _ := markUnknown(redactSensitive(change.Change.After, change.Change.AfterSensitive), change.Change.AfterUnknown).(map[string]interface{})
+
+	beforeRules := make(map[firewallRule]bool)
+	afterRules := make(map[firewallRule]bool)
+	var rules []firewallRule
+	if before != nil {
+		for _, rule := range readRules(before) {
+			if !beforeRules[rule] && !afterRules[rule] {
+				rules = append(rules, rule)
+			}
+			beforeRules[rule] = true
+		}
+	}
+	if after != nil {
+		for _, rule := range readRules(after) {
+			if !beforeRules[rule] && !afterRules[rule] {
+				rules = append(rules, rule)
+			}
+			afterRules[rule] = true
+		}
+	}
+	sortFirewallRules(rules)
+
+	diff := &firewallDiff{}
+	changed := false
+	for _, rule := range rules {
+		row := firewallRuleChange{Kind: diffUnchanged, Rule: rule}
+		switch {
+		case !beforeRules[rule]:
+			row.Kind = diffAdded
+			changed = true
+			if warning := rule.openWarning(); warning != "" {
+				diff.Warnings = append(diff.Warnings, warning)
+			}
+		case !afterRules[rule]:
+			row.Kind = diffRemoved
+			changed = true
+		}
+		diff.Rules = append(diff.Rules, row)
+	}
+
+	if !changed {
+		return nil
+	}
+	return diff
+}
+
+// openWarning flags an allow rule that lets traffic in from any address
+func (r firewallRule) openWarning() string {
+	if r.Direction != "ingress" || r.Access != "allow" || !openPeers[r.Peer] {
+		return ""
+	}
+	ports := "port " + r.Ports
+	switch {
+	case r.Ports == "all" || r.Ports == "*":
+		ports = "all ports"
+	case strings.Contains(r.Ports, "-"):
+		ports = "ports " + r.Ports
+	}
+	return fmt.Sprintf("New ingress rule allows %s traffic on %s from %s, which is open to the whole internet.",
+		r.Protocol, ports, r.Peer)
+}
+
+func sortFirewallRules(rules []firewallRule) {
+	sort.Slice(rules, func(i, j int) bool {
+		a, b := rules[i], rules[j]
+		if a.Direction != b.Direction {
+			return a.Direction > b.Direction // ingress before egress
+		}
+		if a.Access != b.Access {
+			return a.Access < b.Access
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		if a.Ports != b.Ports {
+			return a.Ports < b.Ports
+		}
+		return a.Peer < b.Peer
+	})
+}
+
+// awsSecurityGroupRules reads the inline ingress and egress blocks of an
+// aws_security_group
+func awsSecurityGroupRules(values map[string]interface{}) []firewallRule {
+	var rules []firewallRule
+	for _, direction := range []string{"ingress", "egress"} {
+		for _, block := range ruleBlocks(values[direction]) {
+			rules = append(rules, awsRules(direction, block, "security_groups")...)
+		}
+	}
+	return rules
+}
+
+// awsSecurityGroupRuleRules reads a standalone aws_security_group_rule
+func awsSecurityGroupRuleRules(values map[string]interface{}) []firewallRule {
+	return awsRules(ruleString(values["type"]), values, "source_security_group_id")
+}
+
+// awsRules expands an AWS rule into one rule per CIDR block, prefix list or
+// security group it applies to. groupsKey names the attribute holding the
+// security groups, which differs between inline and standalone rules.
+func awsRules(direction string, rule map[string]interface{}, groupsKey string) []firewallRule {
+	protocol := ruleString(rule["protocol"])
+	ports := awsPortRange(rule["from_port"], rule["to_port"])
+	if protocol == "-1" {
+		protocol, ports = "all", "all"
+	}
+
+	var peers []string
+	for _, key := range []string{"cidr_blocks", "ipv6_cidr_blocks", "prefix_list_ids", groupsKey} {
+		peers = append(peers, ruleStrings(rule[key])...)
+	}
+	if self, ok := rule["self"].(bool); ok && self {
+		peers = append(peers, "self")
+	}
+
+	return expandRules(firewallRule{Direction: direction, Access: "allow", Protocol: protocol}, []string{ports}, peers)
+}
+
+func awsPortRange(from, to interface{}) string {
+	fromPort, toPort := ruleString(from), ruleString(to)
+	if fromPort == toPort {
+		return fromPort
+	}
+	return fromPort + "-" + toPort
+}
+
+// googleComputeFirewallRules reads the allow and deny blocks of a
+// google_compute_firewall, which apply to its source or destination ranges
+// depending on its direction
+func googleComputeFirewallRules(values map[string]interface{}) []firewallRule {
+	direction := "ingress"
+	var peers []string
+	if strings.EqualFold(ruleString(values["direction"]), "EGRESS") {
+		direction = "egress"
+		peers = ruleStrings(values["destination_ranges"])
+	} else {
+		peers = ruleStrings(values["source_ranges"])
+		for _, tag := range ruleStrings(values["source_tags"]) {
+			peers = append(peers, "tag:"+tag)
+		}
+		for _, account := range ruleStrings(values["source_service_accounts"]) {
+			peers = append(peers, "serviceAccount:"+account)
+		}
+	}
+
+	var rules []firewallRule
+	for _, access := range []string{"allow", "deny"} {
+		for _, block := range ruleBlocks(values[access]) {
+			ports := ruleStrings(block["ports"])
+			if len(ports) == 0 {
+				ports = []string{"all"}
+			}
+			rule := firewallRule{Direction: direction, Access: access, Protocol: ruleString(block["protocol"])}
+			rules = append(rules, expandRules(rule, ports, peers)...)
+		}
+	}
+	return rules
+}
+
+// azurermNetworkSecurityGroupRules reads the security_rule blocks of an
+// azurerm_network_security_group. Inbound rules are keyed by their source
+// and outbound rules by their destination.
+func azurermNetworkSecurityGroupRules(values map[string]interface{}) []firewallRule {
+	var rules []firewallRule
+	for _, block := range ruleBlocks(values["security_rule"]) {
+		rule := firewallRule{
+			Direction: "ingress",
+			Access:    strings.ToLower(ruleString(block["access"])),
+			Protocol:  strings.ToLower(ruleString(block["protocol"])),
+		}
+		peerKey := "source_address_prefix"
+		if strings.EqualFold(ruleString(block["direction"]), "Outbound") {
+			rule.Direction = "egress"
+			peerKey = "destination_address_prefix"
+		}
+
+		ports := append(ruleStrings(block["destination_port_range"]), ruleStrings(block["destination_port_ranges"])...)
+		peers := append(ruleStrings(block[peerKey]), ruleStrings(block[peerKey+"es"])...)
+		rules = append(rules, expandRules(rule, ports, peers)...)
+	}
+	return rules
+}
+
+// expandRules makes one copy of rule for every combination of port range and
+// peer. A rule without ports or peers is kept with that part left empty.
+func expandRules(rule firewallRule, ports, peers []string) []firewallRule {
+	if len(ports) == 0 {
+		ports = []string{""}
+	}
+	if len(peers) == 0 {
+		peers = []string{""}
+	}
+
+	var rules []firewallRule
+	for _, port := range ports {
+		for _, peer := range peers {
+			expanded := rule
+			expanded.Ports = port
+			expanded.Peer = peer
+			rules = append(rules, expanded)
+		}
+	}
+	return rules
+}
+
+// ruleBlocks returns the objects of a nested block list or set
+func ruleBlocks(value interface{}) []map[string]interface{} {
+	list, _ := value.([]interface{})
+	var blocks []map[string]interface{}
+	for _, item := range list {
+		if block, ok := item.(map[string]interface{}); ok {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// ruleStrings returns an attribute holding a string or a list of strings as
+// a list, leaving out empty strings
+func ruleStrings(value interface{}) []string {
+	var values []string
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if s := ruleString(item); s != "" {
+				values = append(values, s)
+			}
+		}
+	default:
+		if s := ruleString(v); s != "" {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+func ruleString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
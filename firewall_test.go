@@ -0,0 +1,168 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"cloudvic-tf-plan-viz/plan"
+)
+
+func awsIngress(from, to float64, protocol string, cidrs ...interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"from_port": from, "to_port": to, "protocol": protocol,
+		"cidr_blocks": cidrs, "ipv6_cidr_blocks": []interface{}{}, "prefix_list_ids": []interface{}{},
+		"security_groups": []interface{}{}, "self": false, "description": "",
+	}
+}
+
+func withDescription(rule map[string]interface{}, description string) map[string]interface{} {
+	copied := map[string]interface{}{}
+	for key, value := range rule {
+		copied[key] = value
+	}
+	copied["description"] = description
+	return copied
+}
+
+func TestChangeFirewallDiff(t *testing.T) {
+	https := awsIngress(443, 443, "tcp", "10.0.0.0/8", "10.1.0.0/16")
+	sshInternal := awsIngress(22, 22, "tcp", "10.0.0.0/8")
+	allEgress := map[string]interface{}{"from_port": 0.0, "to_port": 0.0, "protocol": "-1", "cidr_blocks": []interface{}{"0.0.0.0/0"}}
+
+	tests := []struct {
+		name         string
+		resourceType string
+		before       map[string]interface{}
+		after        map[string]interface{}
+		afterUnknown interface{}
+		rows         []string
+		warnings     []string
+	}{
+		{
+			name:         "reordered rules and CIDR blocks are unchanged",
+			resourceType: "aws_security_group",
+			before:       map[string]interface{}{"ingress": []interface{}{https, sshInternal}},
+			after:        map[string]interface{}{"ingress": []interface{}{sshInternal, awsIngress(443, 443, "tcp", "10.1.0.0/16", "10.0.0.0/8")}},
+		},
+		{
+			name:         "description changes are unchanged",
+			resourceType: "aws_security_group",
+			before:       map[string]interface{}{"ingress": []interface{}{https}},
+			after:        map[string]interface{}{"ingress": []interface{}{withDescription(https, "https")}},
+		},
+		{
+			name:         "a CIDR block added to a rule is one new row",
+			resourceType: "aws_security_group",
+			before:       map[string]interface{}{"ingress": []interface{}{sshInternal}, "egress": []interface{}{allEgress}},
+			after:        map[string]interface{}{"ingress": []interface{}{awsIngress(22, 22, "tcp", "10.0.0.0/8", "192.168.0.0/16")}, "egress": []interface{}{allEgress}},
+			rows: []string{
+				"  ingress allow tcp 22 10.0.0.0/8",
+				"+ ingress allow tcp 22 192.168.0.0/16",
+				"  egress allow all all 0.0.0.0/0",
+			},
+		},
+		{
+			name:         "new IPv4 and IPv6 ingress open to the internet",
+			resourceType: "aws_security_group",
+			before:       map[string]interface{}{"ingress": []interface{}{sshInternal}},
+			after:        map[string]interface{}{"ingress": []interface{}{sshInternal, awsIngress(22, 22, "tcp", "0.0.0.0/0"), awsIngress(8000, 8100, "tcp", "::/0")}},
+			rows: []string{
+				"+ ingress allow tcp 22 0.0.0.0/0",
+				"  ingress allow tcp 22 10.0.0.0/8",
+				"+ ingress allow tcp 8000-8100 ::/0",
+			},
+			warnings: []string{"tcp traffic on port 22 from 0.0.0.0/0", "tcp traffic on ports 8000-8100 from ::/0"},
+		},
+		{
+			name:         "open egress is not flagged",
+			resourceType: "aws_security_group",
+			before:       map[string]interface{}{"egress": []interface{}{}},
+			after:        map[string]interface{}{"egress": []interface{}{allEgress}},
+			rows:         []string{"+ egress allow all all 0.0.0.0/0"},
+		},
+		{
+			name:         "standalone rule with an unknown source group",
+			resourceType: "aws_security_group_rule",
+			after:        map[string]interface{}{"type": "ingress", "from_port": 5432.0, "to_port": 5432.0, "protocol": "tcp", "cidr_blocks": nil},
+			afterUnknown: map[string]interface{}{"source_security_group_id": true},
+			rows:         []string{"+ ingress allow tcp 5432 " + unknownPlaceholder},
+		},
+		{
+			name:         "firewall ports are expanded",
+			resourceType: "google_compute_firewall",
+			before: map[string]interface{}{"direction": "INGRESS", "source_ranges": []interface{}{"10.0.0.0/8"},
+				"allow": []interface{}{map[string]interface{}{"protocol": "tcp", "ports": []interface{}{"80"}}}},
+			after: map[string]interface{}{"direction": "INGRESS", "source_ranges": []interface{}{"0.0.0.0/0"},
+				"allow": []interface{}{map[string]interface{}{"protocol": "tcp", "ports": []interface{}{"443", "80"}}},
+				"deny":  []interface{}{map[string]interface{}{"protocol": "udp"}}},
+			rows: []string{
+				"+ ingress allow tcp 443 0.0.0.0/0",
+				"+ ingress allow tcp 80 0.0.0.0/0",
+				"- ingress allow tcp 80 10.0.0.0/8",
+				"+ ingress deny udp all 0.0.0.0/0",
+			},
+			warnings: []string{"port 443 from 0.0.0.0/0", "port 80 from 0.0.0.0/0"},
+		},
+		{
+			name:         "network security group rules use the source inbound and the destination outbound",
+			resourceType: "azurerm_network_security_group",
+			after: map[string]interface{}{"security_rule": []interface{}{
+				map[string]interface{}{"direction": "Inbound", "access": "Allow", "protocol": "Tcp",
+					"destination_port_range": "3389", "source_address_prefix": "Internet"},
+				map[string]interface{}{"direction": "Outbound", "access": "Allow", "protocol": "*",
+					"destination_port_range": "*", "destination_address_prefix": "*"},
+			}},
+			rows: []string{
+				"+ ingress allow tcp 3389 Internet",
+				"+ egress allow * * *",
+			},
+			warnings: []string{"tcp traffic on port 3389 from Internet"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := plan.Change{Actions: plan.Actions{plan.ActionUpdate}, AfterUnknown: tt.afterUnknown}
+			if tt.before != nil {
+				change.Before = tt.before
+			}
+			if tt.after != nil {
+				change.After = tt.after
+			}
+			diff := changeFirewallDiff(resourceEntry{ResourceChange: plan.ResourceChange{Type: tt.resourceType, Change: change}})
+
+			if tt.rows == nil {
+				if diff != nil {
+					t.Fatalf("got a rule table for an unchanged rule set: %+v", diff.Rules)
+				}
+				return
+			}
+			if diff == nil {
+				t.Fatal("got no rule table")
+			}
+
+			var rows []string
+			for _, row := range diff.Rules {
+				marker := diffLine{Kind: row.Kind}.marker()
+				rows = append(rows, strings.Join([]string{marker, row.Rule.Direction, row.Rule.Access, row.Rule.Protocol, row.Rule.Ports, row.Rule.Peer}, " "))
+			}
+			if strings.Join(rows, "\n") != strings.Join(tt.rows, "\n") {
+				t.Errorf("rules =\n%s\nwant\n%s", strings.Join(rows, "\n"), strings.Join(tt.rows, "\n"))
+			}
+
+			if len(diff.Warnings) != len(tt.warnings) {
+				t.Fatalf("warnings = %q, want %d", diff.Warnings, len(tt.warnings))
+			}
+			for i, want := range tt.warnings {
+				if !strings.Contains(diff.Warnings[i], want) {
+					t.Errorf("warning %q does not contain %q", diff.Warnings[i], want)
+				}
+			}
+		})
+	}
+
+	// Types without a rule reader never get a table
+	if diff := changeFirewallDiff(resourceEntry{ResourceChange: plan.ResourceChange{Type: "aws_instance"}}); diff != nil {
+		t.Errorf("got a rule table for aws_instance")
+	}
+}
@@ -1,491 +1,557 @@
 package main
 
 import (
+	"bytes"
+	"embed"
 	"encoding/json"
 	"fmt"
+	"html/template"
+	"strconv"
 	"strings"
+
+	"cloudvic-tf-plan-viz/plan"
+)
+
+// unknownPlaceholder is rendered for values Terraform only learns during apply.
+const unknownPlaceholder = "(known after apply)"
+
+// Values longer than this, in characters or lines, start out collapsed
+const (
+	longValueLength = 300
+	longValueLines  = 15
 )
 
-func generateHtml(planData interface{}) string {
-	// Parse the plan data to extract resource changes and drift
-	planMap, ok := planData.(map[string]interface{})
-	if !ok {
-		return generateErrorHtml("Invalid plan data format")
+// Changed values longer than this highlight the part that differs
+const inlineDiffLength = 60
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// Every plan-derived string reaches the page through html/template, which
+// escapes it for the context it lands in (text, attribute, URL or script).
+var htmlTemplates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+// reportView is everything the report template renders
+type reportView struct {
+	Summary         []summaryCardView
+	Filters         []filterGroupView
+	ResourceChanges moduleView
+	ResourceCount   int
+	Outputs         []resourceView
+	ChangedOutputs  int
+	Drift           []resourceView
+	ReplacedDrift   []replacedDriftView
+}
+
+// summaryCardView is one card of the summary dashboard
+type summaryCardView struct {
+	Category string
+	Label    string
+	Count    int
+	Detail   string
+}
+
+// filterGroupView is one row of filter chips above the Resource Changes list
+type filterGroupView struct {
+	Key     string
+	Label   string
+	Options []filterOptionView
+}
+
+type filterOptionView struct {
+	Value string
+	Label string
+	Count int
+}
+
+// resourceView is one collapsible entry: a resource change, a drifted
+// resource or an output change
+type resourceView struct {
+	Anchor  string
+	Address string
+	Class   string
+	Actions []actionView
+	Details []detailBlock
+
+	// DeposedKey identifies a deposed object, and Deposed holds the deposed
+	// objects of a resource so they are listed under it
+	DeposedKey string
+	Deposed    []resourceView
+
+	// Categories are the summary categories a resource change is counted
+	// under, space separated, so the dashboard can filter on them
+	Categories string
+
+	// Module, Type and Provider are only set for resource changes, for the
+	// search box and filter chips
+	Module   string
+	Type     string
+	Provider string
+}
+
+// moduleView is a module instance in the Resource Changes tree, with the
+// action counts of everything beneath it. The root module has no Address.
+type moduleView struct {
+	Address   string
+	Name      string
+	Counts    []actionView
+	Resources []resourceView
+	Children  []moduleView
+}
+
+type actionView struct {
+	Class string
+	Label string
+}
+
+// detailBlock is one titled part of an entry's details, holding a diff,
+// a list of attributes or side-by-side before and after columns
+type detailBlock struct {
+	Title      string
+	Note       string
+	Warning    string
+	Lines      []diffLineView
+	Attributes []attributeView
+	Columns    []columnView
+
+	// Policy holds the statement table of an IAM policy attribute
+	Policy []policyRowView
+
+	// Firewall holds the rule table of a security group or firewall
+	Firewall []firewallRowView
+}
+
+type columnView struct {
+	Header     string
+	Attributes []attributeView
+}
+
+type attributeView struct {
+	Key   string
+	Class string
+	Value valueView
+}
+
+type valueView struct {
+	Text      string
+	Multiline bool
+	Sensitive bool
+	Unknown   bool
+
+	// Long values are collapsed behind a "show more" toggle
+	Long bool
+
+	// Segments, when set, split Text to highlight the part that changed,
+	// using the Highlight class
+	Segments  []textSegment
+	Highlight string
+}
+
+// diffLineView is a diffLine resolved into what the template displays
+type diffLineView struct {
+	Indent           int
+	Class            string
+	Marker           string
+	Key              string
+	Open             string
+	Close            string
+	Comment          string
+	Values           []valueView
+	SensitiveChanged bool
+
+	// TextDiff replaces Values with a line diff for multi-line values
+	TextDiff []textDiffLineView
+
+	ForcesReplacement bool
+}
+
+// textDiffLineView is one row of a line diff between multi-line values
+type textDiffLineView struct {
+	Class   string
+	Marker  string
+	Text    string
+	Comment string
+}
+
+// policyRowView is one statement of a policy table. Cells follow the
+// columns Effect, Principal, Action, Resource and Condition.
+type policyRowView struct {
+	Class  string
+	Marker string
+	Label  string
+	Cells  []policyCellView
+}
+
+// policyCellView holds the values of a statement element, and the values it
+// had before when a changed statement changed this element
+type policyCellView struct {
+	Values  []string
+	Before  []string
+	Changed bool
+}
+
+// firewallRowView is one rule of a security group or firewall rule table
+type firewallRowView struct {
+	Class  string
+	Marker string
+	Rule   firewallRule
+}
+
+type replacedDriftView struct {
+	Address string
+	Anchor  string
+}
+
+func generateHtml(planData *plan.Plan, options reportOptions) string {
+	// Extract resource changes and drift
+	resourceChanges := selectResourceChanges(planData, options)
+	driftChanges, replacedDrift := extractDriftChanges(planData)
+	sortResourceChanges(driftChanges, options.SortBy)
+	sortResourceChanges(replacedDrift, sortByAddress)
+	outputChanges := extractOutputChanges(planData)
+
+	view := reportView{
+		Summary:         newSummaryCards(resourceChanges),
+		Filters:         newFilterGroups(resourceChanges),
+		ResourceChanges: newModuleView(buildModuleTree(resourceChanges)),
+		ResourceCount:   len(resourceChanges),
+		Outputs:         newOutputViews(outputChanges),
+		ChangedOutputs:  countChangedOutputs(outputChanges),
 	}
 
-	// Extract resource changes
-	resourceChanges := extractResourceChanges(planMap)
-	driftCount := countDriftChanges(planMap)
+	for _, change := range driftChanges {
+		view.Drift = append(view.Drift, newDriftView(change))
+	}
+	// Drift that Terraform resolves by recreating the resource is listed with
+	// the replacement instead of being repeated
+	for _, change := range replacedDrift {
+		view.ReplacedDrift = append(view.ReplacedDrift, replacedDriftView{
+			Address: change.Address,
+			Anchor:  resourceAnchor(change.Address),
+		})
+	}
 
 	// Generate HTML content
-	html := `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>Terraform Plan</title>
-    <style>
-        body {
-            font-family: Arial, sans-serif;
-            margin: 20px;
-            background-color: #f5f5f5;
-        }
-        .container {
-            max-width: 1200px;
-            margin: 0 auto;
-            background: white;
-            padding: 20px;
-            border-radius: 8px;
-            box-shadow: 0 2px 4px rgba(0,0,0,0.1);
-        }
-        h1 {
-            color: #2c3e50;
-            border-bottom: 2px solid #3498db;
-            padding-bottom: 10px;
-            display: flex;
-            justify-content: space-between;
-            align-items: center;
-        }
-        .source-link {
-            font-size: 14px;
-            font-weight: normal;
-            color: #3498db;
-            text-decoration: none;
-        }
-        .source-link:hover {
-            text-decoration: underline;
-        }
-        .promo-message {
-            text-align: center;
-            margin: 20px 0;
-            font-style: italic;
-        }
-        .promo-link {
-            color: #3498db;
-            text-decoration: none;
-            font-weight: bold;
-            font-style: normal;
-        }
-        .promo-link:hover {
-            text-decoration: underline;
-        }
-        .section-header-row {
-            display: flex;
-            justify-content: space-between;
-            align-items: center;
-            width: 100%;
-        }
-        .section-description {
-            font-size: 14px;
-            font-style: italic;
-            color: #6c757d;
-            margin-bottom: 15px;
-        }
-        .section {
-            margin: 20px 0;
-            padding: 15px;
-            background-color: #ecf0f1;
-            border-radius: 5px;
-        }
-        .resource-item {
-            margin: 10px 0;
-            padding: 10px;
-            background-color: white;
-            border-radius: 3px;
-            border-left: 4px solid #3498db;
-        }
-        .create { border-left-color: #27ae60; }
-        .update { border-left-color: #f39c12; }
-        .delete { border-left-color: #e74c3c; }
-        .no-op { border-left-color: #95a5a6; }
-        .drift { border-left-color: #e67e22; }
-        .replace { border-left-color: #95a5a6; }
-        .action {
-            font-weight: bold;
-            padding: 2px 8px;
-            border-radius: 3px;
-            color: white;
-            font-size: 12px;
-        }
-        .action-create { background-color: #27ae60; }
-        .action-update { background-color: #f39c12; }
-        .action-delete { background-color: #e74c3c; }
-        .action-no-op { background-color: #95a5a6; }
-        .action-drift { background-color: #e67e22; }
-        .action-replace { background: linear-gradient(90deg, #e74c3c 40%, #27ae60 60%); }
-        .resource-address {
-            font-family: monospace;
-            font-weight: bold;
-            color: #2c3e50;
-        }
-        .resource-type {
-            color: #7f8c8d;
-            font-size: 14px;
-        }
-        .resource-attributes {
-            margin-top: 10px;
-            padding: 10px;
-            background-color: #f8f9fa;
-            border-radius: 3px;
-            font-family: monospace;
-            font-size: 12px;
-        }
-        .diff-container {
-            display: flex;
-            gap: 10px;
-            margin-top: 10px;
-        }
-        .diff-container pre {
-            white-space: pre-wrap;
-            word-wrap: break-word;
-            margin: 0;
-            padding: 5px;
-            background-color: rgba(0,0,0,0.05);
-            border-radius: 3px;
-        }
-        .collapsible {
-            cursor: pointer;
-            user-select: none;
-            display: flex;
-            align-items: center;
-            gap: 8px;
-        }
-        .collapsible:hover {
-            background-color: #f0f0f0;
-        }
-        .collapsible::before {
-            content: "▼";
-            font-size: 12px;
-            transition: transform 0.2s;
-            flex-shrink: 0;
-        }
-        .collapsible.collapsed::before {
-            content: "▶";
-        }
-        .collapsible-content {
-            overflow: hidden;
-            transition: opacity 0.3s ease-out, max-height 0.3s ease-out;
-        }
-        .collapsible-content.collapsed {
-            max-height: 0;
-            opacity: 0;
-        }
-        .collapsible-content:not(.collapsed) {
-            max-height: none;
-            opacity: 1;
-        }
-        .diff-column {
-            flex: 1;
-            padding: 10px;
-            border-radius: 3px;
-        }
-        .diff-before {
-            background-color: #f8d7da;
-            border-left: 3px solid #dc3545;
-        }
-        .diff-after {
-            background-color: #d4edda;
-            border-left: 3px solid #28a745;
-        }
-        .diff-header {
-            font-weight: bold;
-            margin-bottom: 10px;
-            color: #495057;
-        }
-        .attribute-item {
-            margin: 5px 0;
-            padding: 3px 0;
-            border-bottom: 1px solid #e9ecef;
-        }
-        .attribute-key {
-            font-weight: bold;
-            color: #495057;
-        }
-        .attribute-value {
-            color: #6c757d;
-            margin-left: 10px;
-        }
-        .attribute-changed {
-            background-color: #fff3cd;
-            border-left: 3px solid #ffc107;
-            padding-left: 8px;
-        }
-        .attribute-added {
-            background-color: #d4edda;
-            border-left: 3px solid #28a745;
-            padding-left: 8px;
-        }
-        .attribute-removed {
-            background-color: #f8d7da;
-            border-left: 3px solid #dc3545;
-            padding-left: 8px;
-        }
-        .attribute-computed {
-            background-color: #e2e3e5;
-            border-left: 3px solid #6c757d;
-            padding-left: 8px;
-        }
-        .summary {
-            display: flex;
-            gap: 20px;
-            margin-bottom: 20px;
-        }
-        .summary-item {
-            flex: 1;
-            text-align: center;
-            padding: 15px;
-            background-color: white;
-            border-radius: 5px;
-        }
-        .summary-number {
-            font-size: 24px;
-            font-weight: bold;
-            color: #2c3e50;
-        }
-        .summary-label {
-            color: #7f8c8d;
-            font-size: 14px;
-        }
-    </style>
-    <script>
-        function toggleCollapsible(element) {
-            const content = element.nextElementSibling;
-            element.classList.toggle('collapsed');
-            content.classList.toggle('collapsed');
-        }
-        
-        // Make individual resource items collapsed by default, but keep main sections open
-        document.addEventListener('DOMContentLoaded', function() {
-            const collapsibles = document.querySelectorAll('.collapsible');
-            collapsibles.forEach(function(element) {
-                // Check if this is a main section (Resource Changes or Resource Drift)
-                const isMainSection = element.querySelector('h2') !== null;
-                
-                if (!isMainSection) {
-                    // Only collapse individual resource items, not main sections
-                    element.classList.add('collapsed');
-                    const content = element.nextElementSibling;
-                    if (content) {
-                        content.classList.add('collapsed');
-                    }
-                } else {
-                    // Check if main section has no resource items
-                    const section = element.closest('.section');
-                    const resourceItems = section.querySelectorAll('.resource-item');
-                    if (resourceItems.length === 0) {
-                        element.classList.add('collapsed');
-                        const content = element.nextElementSibling;
-                        if (content) {
-                            content.classList.add('collapsed');
-                        }
-                    }
-                }
-            });
-        });
-    </script>
-</head>
-<body>
-    <div class="container">
-        <h1>Terraform Plan</h1>
-                
-        <div class="section">
-            <div class="collapsible" onclick="toggleCollapsible(this)">
-                <div class="section-header-row">
-                    <h2>Resource Changes (` + fmt.Sprintf("%d", len(resourceChanges)) + ` total)</h2>
-                    <p class="section-description">Terraform will apply these changes to your resources</p>
-                </div>
-            </div>
-            <div class="collapsible-content">
-                
-                ` + generateResourceChangesHtml(resourceChanges) + `
-            </div>
-        </div>
-        
-        <div class="section">
-            <div class="collapsible collapsed" onclick="toggleCollapsible(this)">
-            <div class="section-header-row">
-                    <h2>Resource Drift (` + fmt.Sprintf("%d", driftCount) + ` total)</h2>
-                    <p class="section-description">Terraform will update these resources to match your configuration</p>
-                </div>
-            </div>
-            <div class="collapsible-content collapsed">
-                <div style="text-align: center; padding: 40px 20px; background-color: #f8f9fa; border-radius: 8px; margin: 20px 0;">
-                    <h3 style="color: #2c3e50; margin-bottom: 15px;">Want to see detailed drift information?</h3>
-                    <p style="color: #6c757d; margin-bottom: 20px; font-size: 16px;">
-                        Get comprehensive drift analysis, cost estimates, and advanced Terraform insights with CloudVIC.
-                    </p>
-                    <a href="https://cloudvic.com" style="display: inline-block; background-color: #3498db; color: white; padding: 12px 24px; text-decoration: none; border-radius: 6px; font-weight: bold; transition: background-color 0.3s;">
-                        Subscribe to CloudVIC
-                    </a>
-                </div>
-            </div>
-        </div>
-    </div>
-    <div class="promo-message">
-        Want to visualize your Terraform plan and state changes over time and link them to your git history?<br>
-        <a href="https://cloudvic.com" class="promo-link">Try CloudVIC</a>
-    </div>
-</body>
-</html>`
-
-	return html
-}
-
-func extractResourceChanges(planMap map[string]interface{}) []map[string]interface{} {
-	var changes []map[string]interface{}
-	var driftAddresses []string
-
-	// First, collect addresses from drift that have delete actions
-	if driftChanges, ok := planMap["resource_drift"].([]interface{}); ok {
-		for _, change := range driftChanges {
-			if changeMap, ok := change.(map[string]interface{}); ok {
-				actions := getActions(changeMap)
-				if len(actions) > 0 && actions[0] == "delete" {
-					address := getString(changeMap, "address")
-					driftAddresses = append(driftAddresses, address)
-				}
+	var html strings.Builder
+	if err := htmlTemplates.ExecuteTemplate(&html, "report.html", view); err != nil {
+		return generateErrorHtml(fmt.Sprintf("rendering report: %v", err))
+	}
+
+	return html.String()
+}
+
+func newOutputViews(outputs []outputEntry) []resourceView {
+	var views []resourceView
+
+	for _, output := range outputs {
+		actions := output.Change.Actions
+
+		// An output declared sensitive hides both sides, whatever the masks say
+		beforeSensitive := output.Change.BeforeSensitive
+		afterSensitive := output.Change.AfterSensitive
+		if output.Sensitive {
+			beforeSensitive = true
+			afterSensitive = true
+		}
+
+		before := formatOutputValue(output.Change.Before, beforeSensitive, nil)
+		after := formatOutputValue(output.Change.After, afterSensitive, output.Change.AfterUnknown)
+
+		var details detailBlock
+		switch actions.Primary() {
+		case "create":
+			details.Attributes = []attributeView{{Key: "Value", Class: "attribute-added", Value: after}}
+		case "delete":
+			details.Attributes = []attributeView{{Key: "Value", Class: "attribute-removed", Value: before}}
+		case "update":
+			details.Columns = []columnView{
+				{Header: "Before", Attributes: []attributeView{{Key: "Value", Class: "attribute-removed", Value: before}}},
+				{Header: "After", Attributes: []attributeView{{Key: "Value", Class: "attribute-added", Value: after}}},
 			}
+		default:
+			details.Attributes = []attributeView{{Key: "Value", Value: after}}
 		}
+
+		views = append(views, resourceView{
+			Address: output.Name,
+			Class:   getActionClass(actions.Primary()),
+			Actions: formatActions(actions),
+			Details: []detailBlock{details},
+		})
 	}
 
-	// Then process resource changes, marking as replace if they also appear in drift
-	if resourceChanges, ok := planMap["resource_changes"].([]interface{}); ok {
-		for _, change := range resourceChanges {
-			if changeMap, ok := change.(map[string]interface{}); ok {
-				// Filter out no-op changes
-				actions := getActions(changeMap)
-				if len(actions) > 0 && actions[0] != "no-op" {
-					address := getString(changeMap, "address")
+	return views
+}
+
+// formatOutputValue renders an output value with its sensitive parts hidden
+// and its unknown parts shown as known after apply. Placeholders inside an
+// object or list are left unquoted and marked, the same way the attribute
+// diff shows them, so they cannot be mistaken for string values.
+func formatOutputValue(value, sensitive, unknown interface{}) valueView {
+	if isSensitive(sensitive) {
+		return valueView{Text: sensitivePlaceholder, Sensitive: true}
+	}
+	if isUnknown(unknown) {
+		return valueView{Text: unknownPlaceholder, Unknown: true}
+	}
+	if !hasSensitive(sensitive) && !hasUnknown(unknown) {
+		return newValueView(formatValue(value), false)
+	}
 
-					// Check if this resource also appears in drift (indicating replace)
-					if contains(driftAddresses, address) && actions[0] == "create" {
-						// Mark as replace operation
-						changeMap["_is_replace"] = true
-					}
+	// The placeholders are encoded as tokens that appear nowhere in the
+	// value, then swapped back in once the value is formatted
+	sensitiveToken := placeholderToken(value, "sensitive")
+	unknownToken := placeholderToken(value, "unknown")
+	text := formatValue(replaceUnknown(replaceSensitive(value, sensitive, sensitiveToken), unknown, unknownToken))
 
-					// Also check if actions contain both create and delete (direct replace)
-					if len(actions) == 2 && contains(actions, "create") && contains(actions, "delete") {
-						changeMap["_is_replace"] = true
-					}
-					changes = append(changes, changeMap)
-				}
-			}
+	var segments []textSegment
+	var plain strings.Builder
+	for text != "" {
+		sensitiveAt := strings.Index(text, `"`+sensitiveToken+`"`)
+		unknownAt := strings.Index(text, `"`+unknownToken+`"`)
+		at, token, placeholder := sensitiveAt, sensitiveToken, textSegment{Text: sensitivePlaceholder, Sensitive: true}
+		if sensitiveAt < 0 || (unknownAt >= 0 && unknownAt < sensitiveAt) {
+			at, token, placeholder = unknownAt, unknownToken, textSegment{Text: unknownPlaceholder, Unknown: true}
 		}
+		if at < 0 {
+			segments = append(segments, textSegment{Text: text})
+			break
+		}
+		if at > 0 {
+			segments = append(segments, textSegment{Text: text[:at]})
+		}
+		segments = append(segments, placeholder)
+		text = text[at+len(token)+2:]
+	}
+	for _, segment := range segments {
+		plain.WriteString(segment.Text)
 	}
 
-	return changes
+	view := newValueView(plain.String(), false)
+	view.Segments = segments
+	return view
 }
 
-func countDriftChanges(planMap map[string]interface{}) int {
-	var replaceAddresses []string
+// placeholderToken returns a string, based on name, that does not occur in
+// the formatted value
+func placeholderToken(value interface{}, name string) string {
+	formatted := formatValue(value)
+	token := "placeholder-" + name
+	for i := 1; strings.Contains(formatted, token); i++ {
+		token = fmt.Sprintf("placeholder-%s-%d", name, i)
+	}
+	return token
+}
 
-	// First, collect addresses that are being replaced (appear in both changes and drift)
-	if driftChanges, ok := planMap["resource_drift"].([]interface{}); ok {
-		for _, change := range driftChanges {
-			if changeMap, ok := change.(map[string]interface{}); ok {
-				actions := getActions(changeMap)
-				if len(actions) > 0 && actions[0] == "delete" {
-					address := getString(changeMap, "address")
+func newResourceViews(changes []resourceEntry) []resourceView {
+	var views []resourceView
 
-					// Check if this address also appears in resource_changes as create
-					if resourceChanges, ok := planMap["resource_changes"].([]interface{}); ok {
-						for _, rc := range resourceChanges {
-							if rcMap, ok := rc.(map[string]interface{}); ok {
-								rcAddress := getString(rcMap, "address")
-								rcActions := getActions(rcMap)
-								if rcAddress == address && len(rcActions) > 0 && rcActions[0] == "create" {
-									replaceAddresses = append(replaceAddresses, address)
-									break
-								}
-							}
-						}
-					}
-				}
+	// Deposed objects share their owner's address, so they are listed under
+	// the owner when it is in the list too
+	owners := make(map[string]bool)
+	for _, change := range changes {
+		if change.Deposed == "" {
+			owners[change.Address] = true
+		}
+	}
+	deposed := make(map[string][]resourceView)
+
+	for _, change := range changes {
+		displayActions := change.displayActions()
+
+		actions := formatActions(displayActions)
+		if change.replaceOrder() != "" {
+			actions = []actionView{{Class: getReplaceActionClass(change), Label: strings.ToUpper(change.actionLabel())}}
+		}
+		if change.isImport() && displayActions[0] != categoryImport {
+			actions = append(actions, actionView{Class: "action-import", Label: "IMPORT"})
+		}
+		if change.isMove() && displayActions[0] != categoryMove {
+			actions = append(actions, actionView{Class: "action-move", Label: "MOVED"})
+		}
+		if change.isHighRiskReplace() {
+			actions = append(actions, actionView{Class: "action-risk", Label: "HIGH RISK"})
+		}
+
+		view := resourceView{
+			Anchor:  resourceAnchor(change.Address),
+			Address: change.Address,
+			Class:   getActionClass(displayActions[0]),
+			Actions: actions,
+			Details: getChangeDetails(change),
+
+			Categories: strings.Join(change.categories(), " "),
+			Module:     change.moduleAddress(),
+			Type:       change.Type,
+			Provider:   change.ProviderName,
+		}
+
+		if change.Deposed != "" {
+			view.Anchor += "-deposed-" + change.Deposed
+			view.DeposedKey = change.Deposed
+			view.Actions = append(view.Actions, actionView{Class: "action-deposed", Label: "DEPOSED"})
+			if owners[change.Address] {
+				deposed[change.Address] = append(deposed[change.Address], view)
+				continue
 			}
 		}
+
+		views = append(views, view)
+	}
+
+	for i := range views {
+		if views[i].DeposedKey == "" {
+			views[i].Deposed = deposed[views[i].Address]
+		}
+	}
+
+	return views
+}
+
+func newSummaryCards(changes []resourceEntry) []summaryCardView {
+	var cards []summaryCardView
+
+	counts := countCategories(changes)
+	for _, category := range summaryCategories {
+		cards = append(cards, summaryCardView{
+			Category: category,
+			Label:    strings.ToUpper(category[:1]) + category[1:],
+			Count:    counts[category],
+			Detail:   getSummaryDetail(category, changes),
+		})
+	}
+
+	return cards
+}
+
+// newFilterGroups builds the filter chips for every action, module, provider
+// and resource type that occurs in the resource changes
+func newFilterGroups(changes []resourceEntry) []filterGroupView {
+	categories := countCategories(changes)
+	modules := make(map[string]int)
+	providers := make(map[string]int)
+	types := make(map[string]int)
+	for _, change := range changes {
+		// Module chips match nested modules too, so they count them as well
+		segments := moduleAddressSegments(change.moduleAddress())
+		if len(segments) == 0 {
+			modules[""]++
+		}
+		for i := range segments {
+			modules[strings.Join(segments[:i+1], ".")]++
+		}
+		providers[change.ProviderName]++
+		types[change.Type]++
+	}
+
+	actionGroup := filterGroupView{Key: "category", Label: "Action"}
+	for _, category := range summaryCategories {
+		if categories[category] > 0 {
+			actionGroup.Options = append(actionGroup.Options, filterOptionView{Value: category, Label: category, Count: categories[category]})
+		}
 	}
 
-	// Count drift changes excluding replace operations
-	driftCount := 0
-	if driftChanges, ok := planMap["resource_drift"].([]interface{}); ok {
-		for _, change := range driftChanges {
-			if changeMap, ok := change.(map[string]interface{}); ok {
-				address := getString(changeMap, "address")
-				if !contains(replaceAddresses, address) {
-					driftCount++
+	groups := []filterGroupView{actionGroup}
+	for _, group := range []struct {
+		key    string
+		label  string
+		counts map[string]int
+	}{
+		{"module", "Module", modules},
+		{"provider", "Provider", providers},
+		{"type", "Type", types},
+	} {
+		view := filterGroupView{Key: group.key, Label: group.label}
+		for _, value := range sortedCountKeys(group.counts) {
+			label := value
+			if value == "" {
+				label = "(root module)"
+				if group.key != "module" {
+					label = "(unknown)"
 				}
 			}
+			view.Options = append(view.Options, filterOptionView{Value: value, Label: label, Count: group.counts[value]})
 		}
+		groups = append(groups, view)
 	}
 
-	return driftCount
+	return groups
 }
 
-func generateResourceChangesHtml(changes []map[string]interface{}) string {
-	if len(changes) == 0 {
-		return "<p>No resource changes detected.</p>"
+// getSummaryDetail returns the breakdown shown under a summary card's label
+func getSummaryDetail(category string, changes []resourceEntry) string {
+	if category != "replace" {
+		return ""
 	}
 
-	var html strings.Builder
-	html.WriteString("<div>")
-
+	orders := make(map[string]int)
 	for _, change := range changes {
-		address := getString(change, "address")
-		actions := getActions(change)
+		if order := change.replaceOrder(); order != "" {
+			orders[order]++
+		}
+	}
 
-		// Check if this is a replace operation
-		var displayActions []string
-		if _, isReplace := change["_is_replace"]; isReplace {
-			displayActions = []string{"replace"}
-		} else {
-			displayActions = actions
-		}
-
-		// Get change details
-		changeDetails := getChangeDetails(change)
-
-		html.WriteString(fmt.Sprintf(`
-			<div class="resource-item %s">
-				<div class="collapsible" onclick="toggleCollapsible(this)">
-					<div>%s</div>
-					<div class="resource-address">%s</div>
-				</div>
-				<div class="collapsible-content">
-					<div class="resource-attributes">
-						%s
-					</div>
-				</div>
-			</div>`,
-			getActionClass(displayActions[0]),
-			formatActions(displayActions),
-			address,
-			changeDetails))
-	}
-
-	html.WriteString("</div>")
-	return html.String()
+	var parts []string
+	for _, order := range []string{replaceCreateFirst, replaceDestroyFirst} {
+		if orders[order] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", orders[order], order))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func newModuleView(node *moduleNode) moduleView {
+	view := moduleView{
+		Address:   node.Address,
+		Name:      node.Name(),
+		Resources: newResourceViews(node.Resources),
+	}
+
+	for _, action := range orderedActionCounts(node.Counts) {
+		view.Counts = append(view.Counts, actionView{
+			Class: "action-" + getActionClass(action),
+			Label: fmt.Sprintf("%d %s", node.Counts[action], action),
+		})
+	}
+
+	for _, child := range node.Children {
+		view.Children = append(view.Children, newModuleView(child))
+	}
+
+	return view
 }
 
-func getString(data map[string]interface{}, key string) string {
-	if val, ok := data[key].(string); ok {
-		return val
+func newDriftView(change resourceEntry) resourceView {
+	actions := change.Change.Actions
+
+	block := detailBlock{Lines: formatDiff(change.Change)}
+	if actions.Delete() {
+		block.Title = "Deleted outside of Terraform"
+	}
+
+	return resourceView{
+		Address: change.Address,
+		Class:   "drift",
+		Actions: formatActions(actions),
+		Details: []detailBlock{block},
 	}
-	return ""
 }
 
-func getActions(change map[string]interface{}) []string {
-	if changeData, ok := change["change"].(map[string]interface{}); ok {
-		if actions, ok := changeData["actions"].([]interface{}); ok {
-			var result []string
-			for _, action := range actions {
-				if actionStr, ok := action.(string); ok {
-					result = append(result, actionStr)
-				}
-			}
-			return result
+// resourceAnchor returns the element id used to link to a resource entry.
+// Letters, digits, underscores and dots are kept, and every other rune,
+// including '-', is written as its code point in hex between dashes, so two
+// addresses never share an anchor.
+func resourceAnchor(address string) string {
+	var anchor strings.Builder
+	anchor.WriteString("change-")
+	for _, r := range address {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '.' {
+			anchor.WriteRune(r)
+		} else {
+			fmt.Fprintf(&anchor, "-%x-", r)
 		}
 	}
-	return []string{"no-op"}
+	return anchor.String()
 }
 
 func getActionClass(action string) string {
@@ -498,191 +564,259 @@ func getActionClass(action string) string {
 		return "delete"
 	case "replace":
 		return "replace"
+	case "read":
+		return "read"
+	case "move":
+		return "move"
+	case "import":
+		return "import"
+	case "forget":
+		return "forget"
 	default:
 		return "no-op"
 	}
 }
 
-func formatActions(actions []string) string {
-	var result []string
-	for _, action := range actions {
-		class := "action-" + getActionClass(action)
-		result = append(result, fmt.Sprintf(`<span class="action %s">%s</span>`, class, strings.ToUpper(action)))
+// getReplaceActionClass returns the badge class for a replacement, with the
+// colours of the gradient in the order the two steps happen
+func getReplaceActionClass(change resourceEntry) string {
+	if change.replaceOrder() == replaceCreateFirst {
+		return "action-replace action-replace-create-first"
 	}
-	return strings.Join(result, " ")
+	return "action-replace"
 }
 
-// Helper function to check if a slice contains a string
-func contains(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
-		}
+func formatActions(actions []string) []actionView {
+	var result []actionView
+	for _, action := range actions {
+		result = append(result, actionView{
+			Class: "action-" + getActionClass(action),
+			Label: strings.ToUpper(action),
+		})
 	}
-	return false
+	return result
 }
 
-func getChangeDetails(change map[string]interface{}) string {
-	var details strings.Builder
+func getChangeDetails(change resourceEntry) []detailBlock {
+	var details []detailBlock
 
-	if changeData, ok := change["change"].(map[string]interface{}); ok {
-		actions := getActions(change)
-		action := actions[0]
+	changeData := change.Change
+	action := changeData.Actions.Primary()
 
-		// Check if this is a replace operation
-		if _, isReplace := change["_is_replace"]; isReplace {
-			// For replace operations, show both before and after
-			details.WriteString("<div class='attribute-item'><span class='attribute-key'>Resource Replacement:</span></div>")
+	// Check if this is a replace operation
+	if change.IsReplace {
+		// For replace operations, diff the current state against the new one
+		details = append(details, detailBlock{Title: "Resource Replacement:", Lines: formatDiff(changeData)})
+	} else if action == plan.ActionCreate {
+		// For creates, every attribute in "after" is an addition
+		details = append(details, detailBlock{Title: "New Resource:", Lines: formatDiff(changeData)})
+	} else if action == plan.ActionDelete {
+		// For deletes, every attribute in "before" is a removal
+		details = append(details, detailBlock{Title: "Resource to Delete:", Lines: formatDiff(changeData)})
+	} else if action == plan.ActionUpdate {
+		// For updates, show only the paths that changed
+		details = append(details, detailBlock{Lines: formatDiff(changeData)})
+	} else if action == plan.ActionForget {
+		// Nothing changes in the cloud, so there is no diff to show
+		details = append(details, detailBlock{Note: forgetDescription})
+	} else if action == plan.ActionRead {
+		// Data sources read during apply only know their configured arguments
+		details = append(details, detailBlock{Title: "Data Source Read:", Lines: formatDiff(changeData)})
+	}
 
-			// Show what's being removed
-			if before, ok := changeData["before"].(map[string]interface{}); ok {
-				details.WriteString("<div class='attribute-item'><span class='attribute-key'>Current State (to be removed):</span></div>")
-				details.WriteString(formatAttributes(before, "attribute-removed"))
+	// Policy documents are also shown statement by statement, and rule
+	// sets rule by rule
+	var warnings []detailBlock
+	if action != plan.ActionForget {
+		if firewall := changeFirewallDiff(change); firewall != nil {
+			details = append(details, detailBlock{Title: "Rules:", Firewall: newFirewallRows(*firewall)})
+			for _, warning := range firewall.Warnings {
+				warnings = append(warnings, detailBlock{Warning: warning})
 			}
-
-			// Show what's being created
-			if after, ok := changeData["after"].(map[string]interface{}); ok {
-				details.WriteString("<div class='attribute-item'><span class='attribute-key'>New State (to be created):</span></div>")
-				details.WriteString(formatAttributes(after, "attribute-added"))
+		}
+		for _, policy := range changePolicyDiffs(changeData) {
+			details = append(details, detailBlock{
+				Title:  fmt.Sprintf("Policy statements (%s):", policy.Path),
+				Policy: newPolicyRows(policy),
+			})
+			for _, warning := range policy.Warnings {
+				warnings = append(warnings, detailBlock{Warning: warning})
 			}
+		}
+	}
 
-			// Show computed/unknown fields
-			if afterUnknown, ok := changeData["after_unknown"].(map[string]interface{}); ok {
-				details.WriteString("<div class='attribute-item'><span class='attribute-key'>Computed Fields:</span></div>")
-				details.WriteString(formatUnknownAttributes(afterUnknown, "attribute-computed"))
-			}
-		} else if action == "create" {
-			// For creates, show the "after" values and "after_unknown" fields
-			if after, ok := changeData["after"].(map[string]interface{}); ok {
-				details.WriteString("<div class='attribute-item'><span class='attribute-key'>New Resource:</span></div>")
-				details.WriteString(formatAttributes(after, "attribute-added"))
-			}
+	// Explain why Terraform chose this action before showing the diff
+	var notes []detailBlock
+	if change.isHighRiskReplace() {
+		notes = append(notes, detailBlock{Warning: highRiskReplaceWarning(change)})
+	}
+	notes = append(notes, warnings...)
+	if change.isMove() {
+		notes = append(notes, detailBlock{Note: fmt.Sprintf("Moved from %s to %s.", change.PreviousAddress, change.Address)})
+	}
+	if change.isImport() {
+		notes = append(notes, detailBlock{Note: describeImport(change)})
+	}
+	if change.Deposed != "" {
+		notes = append(notes, detailBlock{Note: describeDeposed(change)})
+	}
+	if reason := explainChange(change); reason != "" {
+		notes = append(notes, detailBlock{Note: reason})
+	}
 
-			// Also show computed/unknown fields
-			if afterUnknown, ok := changeData["after_unknown"].(map[string]interface{}); ok {
-				details.WriteString("<div class='attribute-item'><span class='attribute-key'>Computed Fields:</span></div>")
-				details.WriteString(formatUnknownAttributes(afterUnknown, "attribute-computed"))
-			}
-		} else if action == "delete" {
-			// For deletes, show only the "before" values
-			if before, ok := changeData["before"].(map[string]interface{}); ok {
-				details.WriteString("<div class='attribute-item'><span class='attribute-key'>Resource to Delete:</span></div>")
-				details.WriteString(formatAttributes(before, "attribute-removed"))
-			}
-		} else if action == "update" {
-			// For updates, show side-by-side diff of only changed fields
-			before, beforeOk := changeData["before"].(map[string]interface{})
-			after, afterOk := changeData["after"].(map[string]interface{})
-
-			if beforeOk && afterOk {
-				changedFields := getChangedFields(before, after)
-				if len(changedFields) > 0 {
-					details.WriteString("<div class='diff-container'>")
-					details.WriteString("<div class='diff-column'>")
-					details.WriteString("<div class='diff-header'>Before</div>")
-					details.WriteString(formatChangedFields(changedFields, before, "attribute-removed"))
-					details.WriteString("</div>")
-
-					details.WriteString("<div class='diff-column'>")
-					details.WriteString("<div class='diff-header'>After</div>")
-					details.WriteString(formatChangedFields(changedFields, after, "attribute-added"))
-					details.WriteString("</div>")
-					details.WriteString("</div>")
+	return append(notes, details...)
+}
+
+// newPolicyRows lays out a policy diff as table rows, showing the old and
+// new values of the elements that changed within a statement
+func newPolicyRows(policy policyDiff) []policyRowView {
+	var rows []policyRowView
+	for _, statement := range policy.Statements {
+		row := policyRowView{
+			Class:  getDiffLineClass(statement.Kind),
+			Marker: diffLine{Kind: statement.Kind}.marker(),
+			Label:  statement.Label,
+		}
+
+		current := statement.After
+		if current == nil {
+			current = statement.Before
+		}
+		for i, values := range current.columns() {
+			cell := policyCellView{Values: values}
+			if statement.Kind == diffChanged {
+				before := statement.Before.columns()[i]
+				if !stringsEqual(before, values) {
+					cell.Before = before
+					cell.Changed = true
 				}
 			}
+			row.Cells = append(row.Cells, cell)
 		}
+		rows = append(rows, row)
 	}
+	return rows
+}
 
-	return details.String()
+func newFirewallRows(firewall firewallDiff) []firewallRowView {
+	var rows []firewallRowView
+	for _, rule := range firewall.Rules {
+		rows = append(rows, firewallRowView{
+			Class:  getDiffLineClass(rule.Kind),
+			Marker: diffLine{Kind: rule.Kind}.marker(),
+			Rule:   rule.Rule,
+		})
+	}
+	return rows
 }
 
-func formatAttributes(attrs map[string]interface{}, cssClass string) string {
-	var result strings.Builder
+// formatDiff renders the recursive diff between the before and after values
+// of a change. A missing side diffs as null, so creates render as additions,
+// deletes as removals and objects deleted outside of Terraform diff cleanly.
+func formatDiff(change plan.Change) []diffLineView {
+	return formatDiffLines(changeDiffLines(change))
+}
 
-	for key, value := range attrs {
-		valueStr := formatValue(value)
-		if valueStr != "" {
-			// Check if the value contains newlines (JSON formatting)
-			if strings.Contains(valueStr, "\n") {
-				result.WriteString(fmt.Sprintf(`
-					<div class="attribute-item %s">
-						<span class="attribute-key">%s:</span>
-						<pre class="attribute-value">%s</pre>
-					</div>`, cssClass, key, valueStr))
-			} else {
-				result.WriteString(fmt.Sprintf(`
-					<div class="attribute-item %s">
-						<span class="attribute-key">%s:</span>
-						<span class="attribute-value">%s</span>
-					</div>`, cssClass, key, valueStr))
-			}
+func formatDiffLines(lines []diffLine) []diffLineView {
+	var views []diffLineView
+
+	for _, line := range lines {
+		view := diffLineView{
+			Indent:  line.Depth * 20,
+			Class:   getDiffLineClass(line.Kind),
+			Marker:  line.marker(),
+			Key:     line.Key,
+			Open:    line.Open,
+			Close:   line.Close,
+			Comment: line.Comment,
+
+			ForcesReplacement: line.ForcesReplacement,
+		}
+
+		switch {
+		case line.Open != "" || line.Close != "" || line.Comment != "":
+		case line.Kind == diffAdded || line.Kind == diffUnchanged:
+			view.Values = []valueView{newValueView(line.After, line.Sensitive)}
+		case line.Kind == diffRemoved:
+			view.Values = []valueView{newValueView(line.Before, line.Sensitive)}
+		case line.Sensitive:
+			// Both sides are hidden, so say that it changed instead of
+			// showing the same placeholder twice
+			view.Values = []valueView{newValueView(line.After, true)}
+			view.SensitiveChanged = true
+		case line.Unknown:
+			view.Values = []valueView{newValueView(line.Before, false), newValueView(line.After, false)}
+		case strings.Contains(line.Before, "\n") || strings.Contains(line.After, "\n"):
+			view.TextDiff = formatTextDiff(unifiedLineDiff(line.BeforeText, line.AfterText))
+		default:
+			view.Values = newChangedValueViews(line.Before, line.After)
+		}
+
+		// The after value is the last one shown
+		if line.Unknown && len(view.Values) > 0 {
+			view.Values[len(view.Values)-1].Unknown = true
 		}
+
+		views = append(views, view)
 	}
 
-	return result.String()
+	return views
 }
 
-func formatUnknownAttributes(attrs map[string]interface{}, cssClass string) string {
-	var result strings.Builder
+func newValueView(valueStr string, sensitive bool) valueView {
+	// Multi-line values (pretty-printed JSON) keep their formatting
+	return valueView{
+		Text:      valueStr,
+		Multiline: strings.Contains(valueStr, "\n"),
+		Sensitive: sensitive,
+		Long:      len([]rune(valueStr)) > longValueLength || strings.Count(valueStr, "\n") >= longValueLines,
+	}
+}
 
-	for key, value := range attrs {
-		// For unknown attributes, we show "known after creation" as the value
-		// The value from after_unknown is typically a boolean indicating if it's unknown
-		if unknownBool, ok := value.(bool); ok && unknownBool {
-			result.WriteString(fmt.Sprintf(`
-				<div class="attribute-item %s">
-					<span class="attribute-key">%s:</span>
-					<span class="attribute-value">known after creation</span>
-				</div>`, cssClass, key))
-		} else if _, ok := value.(map[string]interface{}); ok {
-			// Handle nested unknown objects
-			result.WriteString(fmt.Sprintf(`
-				<div class="attribute-item %s">
-					<span class="attribute-key">%s:</span>
-					<span class="attribute-value">known after creation</span>
-				</div>`, cssClass, key))
-		} else if _, ok := value.([]interface{}); ok {
-			// Handle unknown arrays
-			result.WriteString(fmt.Sprintf(`
-				<div class="attribute-item %s">
-					<span class="attribute-key">%s:</span>
-					<span class="attribute-value">known after creation</span>
-				</div>`, cssClass, key))
+func formatTextDiff(lines []textDiffLine) []textDiffLineView {
+	var views []textDiffLineView
+	for _, line := range lines {
+		if line.Skipped > 0 {
+			views = append(views, textDiffLineView{Comment: line.skippedComment()})
+			continue
 		}
+		views = append(views, textDiffLineView{
+			Class:  getDiffLineClass(line.Kind),
+			Marker: line.marker(),
+			Text:   line.Text,
+		})
 	}
-
-	return result.String()
+	return views
 }
 
-func getChangedFields(before, after map[string]interface{}) []string {
-	var changedFields []string
+// newChangedValueViews renders both sides of a changed value. When either
+// side is long or spans several lines, the part that differs is highlighted
+// so a change deep inside the value stands out.
+func newChangedValueViews(before, after string) []valueView {
+	beforeView := newValueView(before, false)
+	afterView := newValueView(after, false)
 
-	// Check all keys in both before and after
-	allKeys := make(map[string]bool)
-	for key := range before {
-		allKeys[key] = true
-	}
-	for key := range after {
-		allKeys[key] = true
+	long := len([]rune(before)) > inlineDiffLength || len([]rune(after)) > inlineDiffLength
+	if long || beforeView.Multiline || afterView.Multiline {
+		beforeView.Segments, afterView.Segments = inlineDiff(before, after)
+		beforeView.Highlight = "inline-removed"
+		afterView.Highlight = "inline-added"
 	}
 
-	for key := range allKeys {
-		beforeVal, beforeExists := before[key]
-		afterVal, afterExists := after[key]
+	return []valueView{beforeView, afterView}
+}
 
-		// Field was added
-		if !beforeExists && afterExists {
-			changedFields = append(changedFields, key)
-		} else if beforeExists && !afterExists {
-			// Field was removed
-			changedFields = append(changedFields, key)
-		} else if beforeExists && afterExists && !valuesEqual(beforeVal, afterVal) {
-			// Field was changed
-			changedFields = append(changedFields, key)
-		}
+func getDiffLineClass(kind diffKind) string {
+	switch kind {
+	case diffAdded:
+		return "attribute-added"
+	case diffRemoved:
+		return "attribute-removed"
+	case diffChanged:
+		return "attribute-changed"
+	default:
+		return ""
 	}
-
-	return changedFields
 }
 
 func valuesEqual(a, b interface{}) bool {
@@ -737,34 +871,6 @@ func valuesEqual(a, b interface{}) bool {
 	return false
 }
 
-func formatChangedFields(changedFields []string, data map[string]interface{}, cssClass string) string {
-	var result strings.Builder
-
-	for _, key := range changedFields {
-		if value, exists := data[key]; exists {
-			valueStr := formatValue(value)
-			// Show the value even if it's empty (empty strings are valid in diffs)
-
-			// Check if the value contains newlines (JSON formatting)
-			if strings.Contains(valueStr, "\n") {
-				result.WriteString(fmt.Sprintf(`
-					<div class="attribute-item %s">
-						<span class="attribute-key">%s:</span>
-						<pre class="attribute-value">%s</pre>
-					</div>`, cssClass, key, valueStr))
-			} else {
-				result.WriteString(fmt.Sprintf(`
-					<div class="attribute-item %s">
-						<span class="attribute-key">%s:</span>
-						<span class="attribute-value">%s</span>
-					</div>`, cssClass, key, valueStr))
-			}
-		}
-	}
-
-	return result.String()
-}
-
 func formatValue(value interface{}) string {
 	switch v := value.(type) {
 	case string:
@@ -773,18 +879,15 @@ func formatValue(value interface{}) string {
 			// Try to parse and reformat the JSON
 			var jsonData interface{}
 			if err := json.Unmarshal([]byte(v), &jsonData); err == nil {
-				jsonBytes, err := json.MarshalIndent(jsonData, "", "  ")
+				jsonBytes, err := prettyJSON(jsonData)
 				if err == nil {
 					return string(jsonBytes)
 				}
 			}
 		}
-		if len(v) > 100 {
-			return v[:100] + "..."
-		}
 		return v
 	case float64:
-		return fmt.Sprintf("%.0f", v)
+		return strconv.FormatFloat(v, 'f', -1, 64)
 	case bool:
 		return fmt.Sprintf("%t", v)
 	case []interface{}:
@@ -792,14 +895,14 @@ func formatValue(value interface{}) string {
 			return "[]"
 		}
 		// Format as pretty-printed JSON
-		jsonBytes, err := json.MarshalIndent(v, "", "  ")
+		jsonBytes, err := prettyJSON(v)
 		if err != nil {
 			return fmt.Sprintf("[%d items]", len(v))
 		}
 		return string(jsonBytes)
 	case map[string]interface{}:
 		// Format as pretty-printed JSON
-		jsonBytes, err := json.MarshalIndent(v, "", "  ")
+		jsonBytes, err := prettyJSON(v)
 		if err != nil {
 			return fmt.Sprintf("{%d fields}", len(v))
 		}
@@ -811,6 +914,96 @@ func formatValue(value interface{}) string {
 	}
 }
 
+// prettyJSON indents a value for display. HTML escaping is left to the
+// templates, so characters like < and & are kept as they are.
+func prettyJSON(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// markUnknown returns a copy of value where every part after_unknown marks as
+// unknown is replaced with unknownPlaceholder. Unknown parts are usually
+// missing from the planned value altogether, so they are added back in.
+func markUnknown(value, unknown interface{}) interface{} {
+	return replaceUnknown(value, unknown, unknownPlaceholder)
+}
+
+// replaceUnknown is markUnknown with a different replacement for the
+// unknown parts
+func replaceUnknown(value, unknown interface{}, replacement string) interface{} {
+	if unknownBool, ok := unknown.(bool); ok && unknownBool {
+		return replacement
+	}
+
+	switch u := unknown.(type) {
+	case map[string]interface{}:
+		valueMap, ok := value.(map[string]interface{})
+		if !ok || !hasUnknown(u) {
+			return value
+		}
+		marked := make(map[string]interface{}, len(valueMap))
+		for key, child := range valueMap {
+			marked[key] = child
+		}
+		for key, childUnknown := range u {
+			if hasUnknown(childUnknown) {
+				marked[key] = replaceUnknown(valueMap[key], childUnknown, replacement)
+			}
+		}
+		return marked
+	case []interface{}:
+		valueList, ok := value.([]interface{})
+		if !ok || !hasUnknown(u) {
+			return value
+		}
+		length := len(valueList)
+		if len(u) > length {
+			length = len(u)
+		}
+		marked := make([]interface{}, length)
+		for i := range marked {
+			var child, childUnknown interface{}
+			if i < len(valueList) {
+				child = valueList[i]
+			}
+			if i < len(u) {
+				childUnknown = u[i]
+			}
+			marked[i] = replaceUnknown(child, childUnknown, replacement)
+		}
+		return marked
+	}
+
+	return value
+}
+
+// hasUnknown reports whether an after_unknown mask marks anything as unknown
+func hasUnknown(unknown interface{}) bool {
+	switch u := unknown.(type) {
+	case bool:
+		return u
+	case map[string]interface{}:
+		for _, child := range u {
+			if hasUnknown(child) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, child := range u {
+			if hasUnknown(child) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // isJSONString checks if a string contains JSON
 func isJSONString(s string) bool {
 	if len(s) == 0 {
@@ -823,9 +1016,9 @@ func isJSONString(s string) bool {
 }
 
 func generateErrorHtml(message string) string {
-	return fmt.Sprintf(`<!DOCTYPE html>
-<html>
-<head><title>Error</title></head>
-<body><h1>Error</h1><p>%s</p></body>
-</html>`, message)
+	var html strings.Builder
+	if err := htmlTemplates.ExecuteTemplate(&html, "error.html", message); err != nil {
+		return "<!DOCTYPE html><html><body><h1>Error</h1></body></html>"
+	}
+	return html.String()
 }
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"cloudvic-tf-plan-viz/plan"
+)
+
+// htmlTag is a start tag found in generated HTML
+type htmlTag struct {
+	Name       string
+	Attributes map[string]string
+}
+
+// The elements and attributes the report template writes. Anything else in
+// the output must have come from plan data.
+var (
+	reportElements = map[string]bool{
+		"html": true, "head": true, "meta": true, "title": true, "style": true, "script": true, "body": true,
+		"div": true, "span": true, "h1": true, "h2": true, "p": true, "a": true, "br": true, "button": true,
+		"input": true, "pre": true, "mark": true, "table": true, "thead": true, "tbody": true, "tr": true,
+		"th": true, "td": true,
+	}
+	reportAttributes = map[string]bool{
+		"charset": true, "class": true, "content": true, "href": true, "id": true, "lang": true, "name": true,
+		"onclick": true, "oninput": true, "placeholder": true, "style": true, "type": true,
+		"data-categories": true, "data-category": true, "data-filter": true, "data-module": true,
+		"data-provider": true, "data-type": true, "data-value": true,
+	}
+)
+
+// parseStartTags returns the start tags of an HTML document, skipping the
+// contents of script and style elements. Attribute values are returned as
+// written, without decoding entities.
+func parseStartTags(t *testing.T, doc string) ([]htmlTag, []string) {
+	t.Helper()
+
+	var tags []htmlTag
+	var scripts []string
+	for i := 0; i < len(doc); i++ {
+		if doc[i] != '<' || i+1 >= len(doc) || !isASCIILetter(doc[i+1]) {
+			continue
+		}
+
+		j := i + 1
+		for j < len(doc) && (isASCIILetter(doc[j]) || doc[j] >= '0' && doc[j] <= '9') {
+			j++
+		}
+		tag := htmlTag{Name: strings.ToLower(doc[i+1 : j]), Attributes: map[string]string{}}
+
+		// Attributes run up to the closing '>', which may not appear inside
+		// a quoted value
+		for {
+			for j < len(doc) && strings.ContainsRune(" \t\n\r/", rune(doc[j])) {
+				j++
+			}
+			if j >= len(doc) {
+				t.Fatalf("unterminated <%s> tag", tag.Name)
+			}
+			if doc[j] == '>' {
+				break
+			}
+			start := j
+			for j < len(doc) && !strings.ContainsRune(" \t\n\r/>=", rune(doc[j])) {
+				j++
+			}
+			name := strings.ToLower(doc[start:j])
+			value := ""
+			if j < len(doc) && doc[j] == '=' {
+				j++
+				if j < len(doc) && (doc[j] == '"' || doc[j] == '\'') {
+					end := strings.IndexByte(doc[j+1:], doc[j])
+					if end < 0 {
+						t.Fatalf("unterminated %s attribute on <%s>", name, tag.Name)
+					}
+					value = doc[j+1 : j+1+end]
+					j += end + 2
+				} else {
+					start := j
+					for j < len(doc) && !strings.ContainsRune(" \t\n\r>", rune(doc[j])) {
+						j++
+					}
+					value = doc[start:j]
+				}
+			}
+			tag.Attributes[name] = value
+		}
+		tags = append(tags, tag)
+		i = j
+
+		if tag.Name == "script" || tag.Name == "style" {
+			end := strings.Index(doc[i:], "</"+tag.Name)
+			if end < 0 {
+				t.Fatalf("unterminated <%s> element", tag.Name)
+			}
+			if tag.Name == "script" {
+				scripts = append(scripts, doc[i+1:i+end])
+			}
+			i += end
+		}
+	}
+	return tags, scripts
+}
+
+// markdownCodeSpan matches the code spans markdownCode writes for values
+// without backticks
+var markdownCodeSpan = regexp.MustCompile("`[^`]*`")
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func TestHostilePlanIsEscaped(t *testing.T) {
+	data, err := os.ReadFile("testdata/hostile-plan.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	planData, err := plan.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sortBy := range sortKeys {
+		doc := generateHtml(planData, reportOptions{SortBy: sortBy})
+		tags, scripts := parseStartTags(t, doc)
+
+		for _, tag := range tags {
+			if !reportElements[tag.Name] {
+				t.Errorf("-sort %s: unexpected <%s> element", sortBy, tag.Name)
+			}
+			for name, value := range tag.Attributes {
+				if !reportAttributes[name] {
+					t.Errorf("-sort %s: unexpected %s attribute on <%s>", sortBy, name, tag.Name)
+				}
+				if (strings.HasPrefix(name, "on") || name == "href" || name == "style") && strings.Contains(value, "alert(") {
+					t.Errorf("-sort %s: plan data reached the %s attribute of <%s>: %s", sortBy, name, tag.Name, value)
+				}
+			}
+		}
+
+		if len(scripts) != 1 {
+			t.Errorf("-sort %s: found %d script elements, want 1", sortBy, len(scripts))
+		}
+		for _, script := range scripts {
+			if strings.Contains(script, "alert(") {
+				t.Errorf("-sort %s: plan data reached the report script", sortBy)
+			}
+		}
+	}
+
+	// Markdown summaries and notes are raw HTML, so the same payloads must
+	// be escaped everywhere outside code blocks and code spans
+	md := generateMarkdown(planData, reportOptions{SortBy: sortByAddress})
+	inFence := false
+	for _, line := range strings.Split(md, "\n") {
+		if strings.HasPrefix(line, "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		line = markdownCodeSpan.ReplaceAllString(line, "")
+		for _, payload := range []string{"<script", "<img", "<svg", "<b>"} {
+			if strings.Contains(line, payload) {
+				t.Errorf("markdown contains %q outside of code: %s", payload, line)
+			}
+		}
+	}
+}
+
+func TestResourceAnchor(t *testing.T) {
+	addresses := []string{
+		`aws_instance.web`,
+		`aws_instance.web["a b"]`,
+		`aws_instance.web["a-b"]`,
+		`aws_instance.web["a_b"]`,
+		`aws_instance.web["a.b"]`,
+		`aws_instance.web["é"]`,
+		`aws_instance.web["ü"]`,
+		`aws_instance.web[0]`,
+		`aws_instance.web["0"]`,
+		`module.a-b.aws_instance.web`,
+		`module.a-2d-b.aws_instance.web`,
+	}
+
+	valid := regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	seen := make(map[string]string)
+	for _, address := range addresses {
+		anchor := resourceAnchor(address)
+		if !valid.MatchString(anchor) {
+			t.Errorf("resourceAnchor(%q) = %q, want only letters, digits, '_', '.' and '-'", address, anchor)
+		}
+		if other, ok := seen[anchor]; ok {
+			t.Errorf("resourceAnchor(%q) = %q, the same as for %q", address, anchor, other)
+		}
+		seen[anchor] = address
+	}
+
+	if got, want := resourceAnchor(`aws_instance.web["a b"]`), "change-aws_instance.web-5b--22-a-20-b-22--5d-"; got != want {
+		t.Errorf("resourceAnchor() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatOutputValue(t *testing.T) {
+	tests := []struct {
+		name                 string
+		value                interface{}
+		sensitive, unknown   interface{}
+		wantText             string
+		wantHTML             string
+		wantSensitiveSegment bool
+		wantUnknownSegment   bool
+	}{
+		{
+			name:               "unknown attribute",
+			value:              map[string]interface{}{"a": "x"},
+			unknown:            map[string]interface{}{"b": true},
+			wantText:           "{\n  \"a\": \"x\",\n  \"b\": (known after apply)\n}",
+			wantHTML:           `&#34;b&#34;: <span class="attribute-unknown">(known after apply)</span>`,
+			wantUnknownSegment: true,
+		},
+		{
+			name:                 "sensitive list element",
+			value:                []interface{}{"public", "topsecret"},
+			sensitive:            []interface{}{false, true},
+			wantText:             "[\n  \"public\",\n  (sensitive value)\n]",
+			wantHTML:             `<span class="attribute-sensitive">(sensitive value)</span>`,
+			wantSensitiveSegment: true,
+		},
+		{
+			name:                 "sensitive and unknown attributes",
+			value:                map[string]interface{}{"password": "topsecret"},
+			sensitive:            map[string]interface{}{"password": true},
+			unknown:              map[string]interface{}{"id": true},
+			wantText:             "{\n  \"id\": (known after apply),\n  \"password\": (sensitive value)\n}",
+			wantSensitiveSegment: true,
+			wantUnknownSegment:   true,
+		},
+		{
+			name:     "string that looks like a placeholder",
+			value:    map[string]interface{}{"a": "(known after apply)"},
+			wantText: "{\n  \"a\": \"(known after apply)\"\n}",
+		},
+		{
+			name:               "string that looks like a token",
+			value:              map[string]interface{}{"a": "placeholder-unknown"},
+			unknown:            map[string]interface{}{"b": true},
+			wantText:           "{\n  \"a\": \"placeholder-unknown\",\n  \"b\": (known after apply)\n}",
+			wantUnknownSegment: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			view := formatOutputValue(tt.value, tt.sensitive, tt.unknown)
+			if view.Text != tt.wantText {
+				t.Errorf("text = %q, want %q", view.Text, tt.wantText)
+			}
+
+			var sensitive, unknown bool
+			for _, segment := range view.Segments {
+				sensitive = sensitive || segment.Sensitive
+				unknown = unknown || segment.Unknown
+			}
+			if sensitive != tt.wantSensitiveSegment || unknown != tt.wantUnknownSegment {
+				t.Errorf("marked sensitive = %t, unknown = %t, want %t, %t", sensitive, unknown, tt.wantSensitiveSegment, tt.wantUnknownSegment)
+			}
+
+			var html strings.Builder
+			if err := htmlTemplates.ExecuteTemplate(&html, "value", view); err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(html.String(), "topsecret") {
+				t.Errorf("rendered value contains a sensitive value: %s", html.String())
+			}
+			if tt.wantHTML != "" && !strings.Contains(html.String(), tt.wantHTML) {
+				t.Errorf("rendered value does not contain %q:\n%s", tt.wantHTML, html.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+
+	"cloudvic-tf-plan-viz/plan"
+)
+
+// planSummary is the normalized summary written by -format json. It gives
+// pipeline scripts the same numbers and replace detection as the report
+// without having to interpret the raw plan themselves.
+type planSummary struct {
+	FormatVersion    string `json:"format_version"`
+	TerraformVersion string `json:"terraform_version,omitempty"`
+	Applyable        bool   `json:"applyable"`
+	Complete         bool   `json:"complete"`
+	Errored          bool   `json:"errored"`
+
+	// Counts and Resources are keyed by the summary categories of the
+	// report, so a replacement is counted once under "replace" rather than
+	// as a create and a delete. Imports and moves can accompany another
+	// action and are then counted under both, as on the HTML dashboard.
+	Counts    map[string]int      `json:"counts"`
+	Resources map[string][]string `json:"resources"`
+
+	// Replacements are also listed by the order they happen in, and
+	// destroy-first replacements of stateful resources are flagged
+	CreateBeforeDestroy []string `json:"create_before_destroy"`
+	DestroyBeforeCreate []string `json:"destroy_before_create"`
+	HighRisk            []string `json:"high_risk"`
+
+	// Moves lists every resource with a new address, including moves that
+	// are combined with another action
+	Moves []moveSummary `json:"moves"`
+
+	// Imports lists every resource imported by an import block, including
+	// imports that are combined with another action
+	Imports []importSummary `json:"imports"`
+
+	// Deposed lists objects left over from failed create-before-destroy
+	// replacements. They are counted as deletes like in the report, and are
+	// listed here as well because they share the address of the current
+	// object.
+	Deposed []deposedSummary `json:"deposed"`
+
+	DriftCount    int             `json:"drift_count"`
+	OutputChanges []outputSummary `json:"output_changes"`
+}
+
+// moveSummary is a resource moved to a new address
+type moveSummary struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// importSummary is a resource imported by an import block
+type importSummary struct {
+	Address string `json:"address"`
+	ID      string `json:"id"`
+}
+
+// deposedSummary is a deposed object Terraform will destroy
+type deposedSummary struct {
+	Address string `json:"address"`
+	Key     string `json:"key"`
+}
+
+// outputSummary is a changed root module output
+type outputSummary struct {
+	Name    string   `json:"name"`
+	Actions []string `json:"actions"`
+}
+
+// generateJSONSummary renders the plan summary as indented JSON
+func generateJSONSummary(planData *plan.Plan, options reportOptions) string {
+	summary := planSummary{
+		FormatVersion:    planData.FormatVersion,
+		TerraformVersion: planData.TerraformVersion,
+		Applyable:        planData.Applyable,
+		Complete:         planData.Complete,
+		Errored:          planData.Errored,
+		Counts:           make(map[string]int),
+		Resources:        make(map[string][]string),
+		DriftCount:       countDriftChanges(planData),
+		OutputChanges:    []outputSummary{},
+
+		CreateBeforeDestroy: []string{},
+		DestroyBeforeCreate: []string{},
+		HighRisk:            []string{},
+		Moves:               []moveSummary{},
+		Imports:             []importSummary{},
+		Deposed:             []deposedSummary{},
+	}
+
+	// Every summary category is always present so scripts can read counts
+	// without checking for missing keys
+	for _, category := range summaryCategories {
+		summary.Counts[category] = 0
+		summary.Resources[category] = []string{}
+	}
+
+	resourceChanges := selectResourceChanges(planData, options)
+	for category, count := range countCategories(resourceChanges) {
+		summary.Counts[category] = count
+	}
+	for _, change := range resourceChanges {
+		for _, category := range change.categories() {
+			summary.Resources[category] = append(summary.Resources[category], change.Address)
+		}
+
+		switch change.replaceOrder() {
+		case replaceCreateFirst:
+			summary.CreateBeforeDestroy = append(summary.CreateBeforeDestroy, change.Address)
+		case replaceDestroyFirst:
+			summary.DestroyBeforeCreate = append(summary.DestroyBeforeCreate, change.Address)
+		}
+		if change.isHighRiskReplace() {
+			summary.HighRisk = append(summary.HighRisk, change.Address)
+		}
+		if change.isImport() {
+			summary.Imports = append(summary.Imports, importSummary{Address: change.Address, ID: change.importID()})
+		}
+		if change.isMove() {
+			summary.Moves = append(summary.Moves, moveSummary{From: change.PreviousAddress, To: change.Address})
+		}
+		if change.Deposed != "" {
+			summary.Deposed = append(summary.Deposed, deposedSummary{Address: change.Address, Key: change.Deposed})
+		}
+	}
+
+	for _, output := range extractOutputChanges(planData) {
+		if output.Change.Actions.NoOp() {
+			continue
+		}
+		summary.OutputChanges = append(summary.OutputChanges, outputSummary{Name: output.Name, Actions: output.Change.Actions})
+	}
+
+	// The summary only holds strings, numbers and booleans, which always
+	// marshal successfully
+	data, _ := json.MarshalIndent(summary, "", "  ")
+	return string(data) + "\n"
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"cloudvic-tf-plan-viz/plan"
+)
+
+func decodeJSONSummary(t *testing.T, p *plan.Plan) planSummary {
+	t.Helper()
+
+	var summary planSummary
+	if err := json.Unmarshal([]byte(generateJSONSummary(p, reportOptions{SortBy: sortByAddress})), &summary); err != nil {
+		t.Fatal(err)
+	}
+	return summary
+}
+
+func TestJSONSummaryDeposed(t *testing.T) {
+	p := &plan.Plan{FormatVersion: "1.2", ResourceChanges: []plan.ResourceChange{
+		{Address: "aws_instance.f", Mode: "managed", Type: "aws_instance", Change: plan.Change{
+			Actions: plan.Actions{plan.ActionCreate, plan.ActionDelete},
+			Before:  map[string]interface{}{"ami": "a"},
+			After:   map[string]interface{}{"ami": "b"},
+		}},
+		{Address: "aws_instance.f", Mode: "managed", Type: "aws_instance", Deposed: "00000001", Change: plan.Change{
+			Actions: plan.Actions{plan.ActionDelete},
+			Before:  map[string]interface{}{"ami": "a"},
+		}},
+	}}
+
+	summary := decodeJSONSummary(t, p)
+	if got := summary.Resources["replace"]; len(got) != 1 || got[0] != "aws_instance.f" {
+		t.Errorf("resources.replace = %v, want [aws_instance.f]", got)
+	}
+	if got := summary.Resources["delete"]; len(got) != 1 || got[0] != "aws_instance.f" {
+		t.Errorf("resources.delete = %v, want the deposed object", got)
+	}
+	if got := summary.Counts["delete"]; got != 1 {
+		t.Errorf("counts.delete = %d, want 1", got)
+	}
+	if len(summary.Deposed) != 1 || summary.Deposed[0] != (deposedSummary{Address: "aws_instance.f", Key: "00000001"}) {
+		t.Errorf("deposed = %v, want aws_instance.f 00000001", summary.Deposed)
+	}
+}
+
+func TestJSONSummaryCategories(t *testing.T) {
+	importing := &plan.Importing{ID: "i-123"}
+	p := &plan.Plan{FormatVersion: "1.2", ResourceChanges: []plan.ResourceChange{
+		{Address: "aws_instance.imported", Change: plan.Change{Actions: plan.Actions{plan.ActionNoOp}, Importing: importing}},
+		{Address: "aws_instance.imported_and_updated", Change: plan.Change{Actions: plan.Actions{plan.ActionUpdate}, Importing: importing}},
+		{Address: "aws_instance.moved", PreviousAddress: "aws_instance.old", Change: plan.Change{Actions: plan.Actions{plan.ActionNoOp}}},
+		{Address: "aws_instance.moved_and_updated", PreviousAddress: "aws_instance.older", Change: plan.Change{Actions: plan.Actions{plan.ActionUpdate}}},
+		{Address: "aws_instance.replaced", Change: plan.Change{Actions: plan.Actions{plan.ActionCreate, plan.ActionDelete}}},
+		{Address: "aws_instance.replaced", Deposed: "00000001", Change: plan.Change{Actions: plan.Actions{plan.ActionDelete}}},
+	}}
+
+	summary := decodeJSONSummary(t, p)
+	want := map[string]int{"create": 0, "update": 2, "delete": 1, "replace": 1, "read": 0, "import": 2, "move": 2, "forget": 0}
+	for category, count := range want {
+		got, ok := summary.Counts[category]
+		if !ok {
+			t.Errorf("counts.%s is missing", category)
+		} else if got != count {
+			t.Errorf("counts.%s = %d, want %d", category, got, count)
+		}
+		if got := len(summary.Resources[category]); got != count {
+			t.Errorf("resources.%s has %d entries, want %d", category, got, count)
+		}
+	}
+
+	// The JSON summary counts the same way as the markdown and HTML reports
+	changes := extractResourceChanges(p)
+	for category, count := range countCategories(changes) {
+		if summary.Counts[category] != count {
+			t.Errorf("counts.%s = %d, but the reports count %d", category, summary.Counts[category], count)
+		}
+	}
+}
@@ -1,11 +1,12 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 	"runtime"
+
+	"cloudvic-tf-plan-viz/plan"
 )
 
 // Version information - set during build
@@ -15,11 +16,41 @@ var (
 	GitCommit = "unknown"
 )
 
+// Output formats supported by -format
+const (
+	formatHTML     = "html"
+	formatMarkdown = "markdown"
+	formatJSON     = "json"
+)
+
+// defaultOutputFiles is the file each format is written to when no output
+// path is given
+var defaultOutputFiles = map[string]string{
+	formatHTML:     "index.html",
+	formatMarkdown: "plan.md",
+	formatJSON:     "plan-summary.json",
+}
+
+// reportOptions controls how a plan is presented in the generated report
+type reportOptions struct {
+	// SortBy is the key resource lists are ordered by: address, module or action
+	SortBy string
+
+	// Format is the report format to generate: html, markdown or json
+	Format string
+
+	// HideReads leaves data source reads out of the report
+	HideReads bool
+}
+
 func main() {
 	// Define command line flags
 	var inputFile = flag.String("i", "", "Input file path (required)")
 	var outputFile = flag.String("o", "index.html", "Output HTML file path (default: index.html)")
 	var outputFileLong = flag.String("output-html-path", "index.html", "Output HTML file path (default: index.html)")
+	var format = flag.String("format", formatHTML, "Output format: html, markdown or json (default: html)")
+	var sortBy = flag.String("sort", sortByAddress, "Sort resources by address, module or action (default: address)")
+	var hideReads = flag.Bool("hide-reads", false, "Leave data source reads out of the report")
 	var showVersion = flag.Bool("v", false, "Show version information")
 	var showHelp = flag.Bool("h", false, "Show help information")
 
@@ -44,10 +75,27 @@ func main() {
 		showUsage()
 		os.Exit(1)
 	}
+	if err := validateSortKey(*sortBy); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		showUsage()
+		os.Exit(1)
+	}
+	if err := validateFormat(*format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		showUsage()
+		os.Exit(1)
+	}
 
-	// Determine output file (prefer -o over --output-html-path if both are set)
-	finalOutputFile := *outputFile
-	if *outputFileLong != "index.html" && *outputFile == "index.html" {
+	// Determine output file (prefer -o over --output-html-path if both are
+	// set). Without either, the default file name depends on the format.
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+	finalOutputFile := defaultOutputFiles[*format]
+	if setFlags["o"] {
+		finalOutputFile = *outputFile
+	} else if setFlags["output-html-path"] {
 		finalOutputFile = *outputFileLong
 	}
 
@@ -56,7 +104,8 @@ func main() {
 	fmt.Printf("Output file: %s\n", finalOutputFile)
 
 	// Process the files
-	if err := processPlanFile(*inputFile, finalOutputFile); err != nil {
+	options := reportOptions{SortBy: *sortBy, Format: *format, HideReads: *hideReads}
+	if err := processPlanFile(*inputFile, finalOutputFile, options); err != nil {
 		fmt.Fprintf(os.Stderr, "Error processing plan file: %v\n", err)
 		os.Exit(1)
 	}
@@ -80,7 +129,14 @@ func validateInput(inputFile string) error {
 	return nil
 }
 
-func processPlanFile(inputFile, outputFile string) error {
+func validateFormat(format string) error {
+	if _, ok := defaultOutputFiles[format]; !ok {
+		return fmt.Errorf("invalid format '%s': must be html, markdown or json", format)
+	}
+	return nil
+}
+
+func processPlanFile(inputFile, outputFile string, options reportOptions) error {
 	fmt.Println("\nProcessing files:")
 
 	// Display file information
@@ -94,32 +150,44 @@ func processPlanFile(inputFile, outputFile string) error {
 	}
 
 	// Parse JSON
-	var planData interface{}
-	if err := json.Unmarshal(jsonData, &planData); err != nil {
-		return fmt.Errorf("parsing plan JSON: %v", err)
+	planData, err := plan.Parse(jsonData)
+	if err != nil {
+		return err
 	}
 
 	fmt.Println("Successfully parsed JSON file!")
 	fmt.Printf("JSON contains %d bytes of data\n", len(jsonData))
 
-	// Generate HTML from the parsed plan data
-	htmlContent := generateHtml(planData)
-	fmt.Printf("Generated HTML content (%d characters)\n", len(htmlContent))
+	// Generate the report from the parsed plan data
+	content := generateReport(planData, options)
+	fmt.Printf("Generated %s content (%d characters)\n", options.Format, len(content))
 
-	// Write HTML to output file
-	if err := writeHtmlFile(outputFile, htmlContent); err != nil {
-		return fmt.Errorf("writing HTML file: %v", err)
+	// Write the report to the output file
+	if err := writeOutputFile(outputFile, content); err != nil {
+		return fmt.Errorf("writing output file: %v", err)
 	}
 
-	fmt.Printf("Successfully wrote HTML to: %s\n", outputFile)
+	fmt.Printf("Successfully wrote %s to: %s\n", options.Format, outputFile)
 	fmt.Println("\nFile processing completed!")
 	return nil
 }
 
-func writeHtmlFile(filePath, htmlContent string) error {
-	err := os.WriteFile(filePath, []byte(htmlContent), 0644)
+// generateReport renders a plan in the format selected by options
+func generateReport(planData *plan.Plan, options reportOptions) string {
+	switch options.Format {
+	case formatMarkdown:
+		return generateMarkdown(planData, options)
+	case formatJSON:
+		return generateJSONSummary(planData, options)
+	default:
+		return generateHtml(planData, options)
+	}
+}
+
+func writeOutputFile(filePath, content string) error {
+	err := os.WriteFile(filePath, []byte(content), 0644)
 	if err != nil {
-		return fmt.Errorf("failed to write HTML file %s: %v", filePath, err)
+		return fmt.Errorf("failed to write file %s: %v", filePath, err)
 	}
 	return nil
 }
@@ -150,9 +218,12 @@ func showHelpInfo() {
 	fmt.Println()
 	fmt.Println("Options:")
 	fmt.Println("  -i, -input string        Input Terraform plan JSON file (required)")
-	fmt.Println("  -o, -output string       Output HTML file path (default: index.html)")
+	fmt.Println("  -o, -output string       Output file path (default: index.html, plan.md or plan-summary.json)")
 	fmt.Println("  --output-html-path string")
 	fmt.Println("                           Output HTML file path (alternative to -o)")
+	fmt.Println("  -format string           Output format: html, markdown or json (default: html)")
+	fmt.Println("  -sort string             Sort resources by address, module or action (default: address)")
+	fmt.Println("  -hide-reads              Leave data source reads out of the report")
 	fmt.Println("  -v, -version             Show version information")
 	fmt.Println("  -h, -help                Show this help information")
 	fmt.Println()
@@ -160,6 +231,9 @@ func showHelpInfo() {
 	fmt.Println("  terraform-plan-visualizer -i plan.json")
 	fmt.Println("  terraform-plan-visualizer -i plan.json -o visualization.html")
 	fmt.Println("  terraform-plan-visualizer -i plan.json --output-html-path my-plan.html")
+	fmt.Println("  terraform-plan-visualizer -i plan.json -sort action")
+	fmt.Println("  terraform-plan-visualizer -i plan.json -format markdown -o plan.md")
+	fmt.Println("  terraform-plan-visualizer -i plan.json -format json -o plan-summary.json")
 	fmt.Println()
 	fmt.Println("For more information, visit: https://github.com/cloudvic-org/terraform-plan-visualizer")
 }
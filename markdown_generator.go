@@ -0,0 +1,492 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"cloudvic-tf-plan-viz/plan"
+)
+
+// markdownCommentLimit is the largest comment GitHub accepts, in characters.
+// GitLab allows more, so staying under GitHub's limit works for both.
+const markdownCommentLimit = 65536
+
+// markdownTruncationReserve keeps room for the notes explaining what was cut
+const markdownTruncationReserve = 1024
+
+// markdownMaxDiffLines caps the diff shown for a single resource so one huge
+// change cannot crowd out every other resource's details
+const markdownMaxDiffLines = 200
+
+// markdownMaxDiffLength caps the same diff in characters, so a single huge
+// value such as a user_data script cannot crowd them out either
+const markdownMaxDiffLength = 8192
+
+// markdownHighRiskListLimit caps the addresses listed in the high-risk
+// replacement warning, in characters
+const markdownHighRiskListLimit = 4096
+
+// generateMarkdown renders a plan as a GitHub/GitLab flavored markdown
+// summary suitable for a pull request comment. The summary comes first, then
+// the resource table, outputs and drift each get a share of the size limit,
+// and per-resource diffs are added in order for as long as they fit.
+func generateMarkdown(planData *plan.Plan, options reportOptions) string {
+	resourceChanges := selectResourceChanges(planData, options)
+	driftChanges, _ := extractDriftChanges(planData)
+	sortResourceChanges(driftChanges, options.SortBy)
+	outputChanges := extractOutputChanges(planData)
+
+	header := generateMarkdownSummary(resourceChanges)
+
+	budget := markdownCommentLimit - markdownTruncationReserve - len(header)
+	table := generateMarkdownTable(resourceChanges, budget/2)
+	budget -= len(table)
+	outputs := generateMarkdownOutputs(outputChanges, budget/4)
+	budget -= len(outputs)
+	drift := generateMarkdownDrift(driftChanges, budget/3)
+	budget -= len(drift)
+
+	var details strings.Builder
+	omitted := 0
+	for _, change := range resourceChanges {
+		block := generateMarkdownDetails(change)
+		if details.Len()+len(block) > budget {
+			omitted++
+			continue
+		}
+		details.WriteString(block)
+	}
+	if omitted > 0 {
+		details.WriteString(fmt.Sprintf("\n> [!NOTE]\n> Attribute diffs for %d of %d resources were omitted to stay within the %d character comment limit. See the HTML report for the full plan.\n",
+			omitted, len(resourceChanges), markdownCommentLimit))
+	}
+
+	var md strings.Builder
+	md.WriteString(header)
+	md.WriteString(table)
+	md.WriteString(details.String())
+	md.WriteString(outputs)
+	md.WriteString(drift)
+	return md.String()
+}
+
+func generateMarkdownSummary(changes []resourceEntry) string {
+	var md strings.Builder
+	md.WriteString("## Terraform Plan\n\n")
+
+	if len(changes) == 0 {
+		md.WriteString("No resource changes. Your infrastructure matches the configuration.\n\n")
+		return md.String()
+	}
+
+	counts := countActions(changes)
+	var parts []string
+	for _, action := range summaryActions {
+		parts = append(parts, fmt.Sprintf("%d to %s", counts[action], action))
+	}
+	// Less common categories, such as data source reads and moves, only
+	// appear when present. Moves and imports can accompany another action,
+	// so they are counted on top of it.
+	categories := countCategories(changes)
+	for _, category := range orderedActionCounts(categories) {
+		if !contains(summaryActions, category) {
+			parts = append(parts, fmt.Sprintf("%d to %s", categories[category], category))
+		}
+	}
+	md.WriteString(fmt.Sprintf("**Plan:** %s.\n\n", strings.Join(parts, ", ")))
+
+	// The list of addresses is capped so a plan replacing thousands of
+	// databases still leaves room for the rest of the comment
+	var highRisk []string
+	count, length := 0, 0
+	for _, change := range changes {
+		if !change.isHighRiskReplace() {
+			continue
+		}
+		count++
+		address := markdownCode(change.Address)
+		if length+len(address) > markdownHighRiskListLimit {
+			continue
+		}
+		length += len(address) + len(", ")
+		highRisk = append(highRisk, address)
+	}
+	if count > len(highRisk) {
+		highRisk = append(highRisk, fmt.Sprintf("and %d more", count-len(highRisk)))
+	}
+	if count > 0 {
+		md.WriteString("> [!WARNING]\n")
+		md.WriteString(fmt.Sprintf("> Stateful resources destroyed before their replacement is created (%d): %s\n\n",
+			count, strings.Join(highRisk, ", ")))
+	}
+
+	return md.String()
+}
+
+// generateMarkdownTable lists every changed resource, dropping rows from the
+// end if the table alone would exceed its share of the size limit
+func generateMarkdownTable(changes []resourceEntry, budget int) string {
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var md strings.Builder
+	md.WriteString("### Resource Changes\n\n")
+	md.WriteString("| Action | Resource |\n")
+	md.WriteString("| --- | --- |\n")
+
+	for i, change := range changes {
+		row := fmt.Sprintf("| %s | %s |\n", markdownActionLabel(change), markdownCode(change.Address))
+		if md.Len()+len(row) > budget {
+			md.WriteString(fmt.Sprintf("\n_%d more resources are not listed to stay within the comment size limit._\n", len(changes)-i))
+			break
+		}
+		md.WriteString(row)
+	}
+
+	md.WriteString("\n")
+	return md.String()
+}
+
+func generateMarkdownDetails(change resourceEntry) string {
+	lines := diffText(changeDiffLines(change.Change))
+	if len(lines) == 0 {
+		return ""
+	}
+	if change.Change.Actions.Forget() {
+		// The before value is not being removed from the cloud, so showing
+		// it as a diff would read like a delete
+		return ""
+	}
+
+	content := strings.Join(truncateDiffText(lines), "\n")
+	fence := markdownFence(content)
+
+	var md strings.Builder
+	label := change.actionLabel()
+	if change.Deposed != "" {
+		label += ", deposed object " + change.Deposed
+	}
+
+	md.WriteString(fmt.Sprintf("<details><summary><code>%s</code> (%s)</summary>\n\n",
+		html.EscapeString(change.Address), html.EscapeString(label)))
+	policies := changePolicyDiffs(change.Change)
+	firewall := changeFirewallDiff(change)
+	var warnings []string
+	if change.isHighRiskReplace() {
+		warnings = append(warnings, highRiskReplaceWarning(change))
+	}
+	if firewall != nil {
+		warnings = append(warnings, firewall.Warnings...)
+	}
+	for _, policy := range policies {
+		warnings = append(warnings, policy.Warnings...)
+	}
+	for _, warning := range warnings {
+		md.WriteString("> [!WARNING]\n> " + html.EscapeString(warning) + "\n\n")
+	}
+	if change.isMove() {
+		md.WriteString(fmt.Sprintf("Moved from <code>%s</code>.\n\n", html.EscapeString(change.PreviousAddress)))
+	}
+	if change.isImport() {
+		md.WriteString(html.EscapeString(describeImport(change)) + "\n\n")
+	}
+	if change.Deposed != "" {
+		md.WriteString(html.EscapeString(describeDeposed(change)) + "\n\n")
+	}
+	if reason := explainChange(change); reason != "" {
+		md.WriteString(html.EscapeString(reason) + "\n\n")
+	}
+	md.WriteString(fence + "diff\n")
+	md.WriteString(content)
+	md.WriteString("\n" + fence + "\n\n")
+	if firewall != nil {
+		md.WriteString(generateMarkdownFirewall(*firewall))
+	}
+	for _, policy := range policies {
+		md.WriteString(generateMarkdownPolicy(policy))
+	}
+	md.WriteString("</details>\n\n")
+	return md.String()
+}
+
+// truncateDiffText keeps a resource's diff within markdownMaxDiffLines lines
+// and markdownMaxDiffLength characters, cutting an overlong line short
+func truncateDiffText(lines []string) []string {
+	var kept []string
+	length := 0
+	for i, line := range lines {
+		if i == markdownMaxDiffLines || length >= markdownMaxDiffLength {
+			return append(kept, fmt.Sprintf("  # (%d more lines not shown, see the HTML report)", len(lines)-i))
+		}
+		if remaining := markdownMaxDiffLength - length; len(line) > remaining {
+			for remaining > 0 && !utf8.RuneStart(line[remaining]) {
+				remaining--
+			}
+			line = line[:remaining] + " # (value cut short, see the HTML report)"
+		}
+		length += len(line) + len("\n")
+		kept = append(kept, line)
+	}
+	return kept
+}
+
+// generateMarkdownFirewall renders the rule table of a security group or
+// firewall
+func generateMarkdownFirewall(firewall firewallDiff) string {
+	var md strings.Builder
+	md.WriteString("Rules:\n\n")
+	md.WriteString("| | Direction | Access | Protocol | Ports | Source / destination |\n")
+	md.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+
+	for _, row := range newFirewallRows(firewall) {
+		marker := ""
+		if row.Marker != " " {
+			marker = markdownCode(row.Marker)
+		}
+		cells := []string{marker}
+		for _, value := range []string{row.Rule.Direction, row.Rule.Access, row.Rule.Protocol, row.Rule.Ports, row.Rule.Peer} {
+			if value != "" {
+				value = markdownCode(value)
+			}
+			cells = append(cells, value)
+		}
+		md.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+
+	md.WriteString("\n")
+	return md.String()
+}
+
+// generateMarkdownPolicy renders the statement table of a policy document,
+// striking through the old values of the elements that changed
+func generateMarkdownPolicy(policy policyDiff) string {
+	var md strings.Builder
+	md.WriteString(fmt.Sprintf("Policy statements (%s):\n\n", markdownCode(policy.Path)))
+	md.WriteString("| | Statement | Effect | Principal | Action | Resource | Condition |\n")
+	md.WriteString("| --- | --- | --- | --- | --- | --- | --- |\n")
+
+	for _, row := range newPolicyRows(policy) {
+		marker := ""
+		if row.Marker != " " {
+			marker = markdownCode(row.Marker)
+		}
+		cells := []string{marker, markdownCode(row.Label)}
+		for _, cell := range row.Cells {
+			var values []string
+			for _, value := range cell.Before {
+				values = append(values, "~~"+markdownCode(value)+"~~")
+			}
+			for _, value := range cell.Values {
+				values = append(values, markdownCode(value))
+			}
+			cells = append(cells, strings.Join(values, "<br>"))
+		}
+		md.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+
+	md.WriteString("\n")
+	return md.String()
+}
+
+// generateMarkdownOutputs lists the changed outputs, dropping rows from the
+// end if the table would exceed its share of the size limit
+func generateMarkdownOutputs(outputs []outputEntry, budget int) string {
+	changed := countChangedOutputs(outputs)
+	if changed == 0 {
+		return ""
+	}
+
+	var md strings.Builder
+	md.WriteString("### Output Changes\n\n")
+	md.WriteString("| Action | Output | Value |\n")
+	md.WriteString("| --- | --- | --- |\n")
+
+	listed := 0
+	for _, output := range outputs {
+		actions := output.Change.Actions
+		if actions.NoOp() {
+			continue
+		}
+
+		sensitive := output.Change.AfterSensitive
+		value := output.Change.After
+		if actions.Delete() {
+			sensitive = output.Change.BeforeSensitive
+			value = output.Change.Before
+		}
+		if output.Sensitive {
+			sensitive = true
+		}
+
+		var unknown interface{}
+		if !actions.Delete() {
+			unknown = output.Change.AfterUnknown
+		}
+
+		view := formatOutputValue(value, sensitive, unknown)
+		valueStr := view.Text
+		switch {
+		case view.Sensitive || view.Unknown:
+			// Placeholders are not values, so they are not shown as code
+			valueStr = "_" + valueStr + "_"
+		case strings.Contains(valueStr, "\n") || len(valueStr) > 80:
+			valueStr = "(see the HTML report)"
+		default:
+			valueStr = markdownCode(valueStr)
+		}
+
+		row := fmt.Sprintf("| %s | %s | %s |\n", strings.Join(actions, ", "), markdownCode(output.Name), valueStr)
+		if md.Len()+len(row) > budget {
+			md.WriteString(fmt.Sprintf("\n_%d more outputs are not listed to stay within the comment size limit._\n", changed-listed))
+			break
+		}
+		md.WriteString(row)
+		listed++
+	}
+
+	md.WriteString("\n")
+	return md.String()
+}
+
+// generateMarkdownDrift lists the drifted resources, dropping rows from the
+// end if the table would exceed its share of the size limit
+func generateMarkdownDrift(drifted []resourceEntry, budget int) string {
+	if len(drifted) == 0 {
+		return ""
+	}
+
+	var md strings.Builder
+	md.WriteString("### Resource Drift\n\n")
+	md.WriteString(fmt.Sprintf("%d resources changed outside of Terraform since the last apply.\n\n", len(drifted)))
+	md.WriteString("| Action | Resource |\n")
+	md.WriteString("| --- | --- |\n")
+	for i, change := range drifted {
+		row := fmt.Sprintf("| %s | %s |\n", strings.Join(change.Change.Actions, ", "), markdownCode(change.Address))
+		if md.Len()+len(row) > budget {
+			md.WriteString(fmt.Sprintf("\n_%d more drifted resources are not listed to stay within the comment size limit._\n", len(drifted)-i))
+			break
+		}
+		md.WriteString(row)
+	}
+	md.WriteString("\n")
+	return md.String()
+}
+
+// markdownActionLabel returns the action shown for a resource, with its
+// deposed key, import ID, where it moved from and a flag on destroy-first
+// replacements of stateful resources
+func markdownActionLabel(change resourceEntry) string {
+	label := change.actionLabel()
+	if change.Change.Actions.Forget() {
+		label += " (removed from state, not destroyed)"
+	}
+	if change.Deposed != "" {
+		label += " (deposed object " + markdownCode(change.Deposed) + ")"
+	}
+	if change.isImport() && change.importID() != "" {
+		if change.displayActions()[0] == categoryImport {
+			label += " " + markdownCode(change.importID())
+		} else {
+			label += ", imported as " + markdownCode(change.importID())
+		}
+	}
+	if change.isMove() {
+		if change.displayActions()[0] == categoryMove {
+			label += " from " + markdownCode(change.PreviousAddress)
+		} else {
+			label += ", moved from " + markdownCode(change.PreviousAddress)
+		}
+	}
+	if change.isHighRiskReplace() {
+		label += " :warning: **high risk**"
+	}
+	return label
+}
+
+// diffText renders diff lines as plain text laid out like terraform plan,
+// keeping the +/-/~ marker in the first column so diff highlighting works
+func diffText(lines []diffLine) []string {
+	var text []string
+
+	for _, line := range lines {
+		indent := strings.Repeat("  ", line.Depth)
+
+		switch {
+		case line.Comment != "":
+			text = append(text, "  "+indent+line.Comment)
+			continue
+		case line.Close != "":
+			text = append(text, line.marker()+" "+indent+line.Close)
+			continue
+		}
+
+		first := len(text)
+		prefix := line.marker() + " " + indent
+		if line.Key != "" {
+			prefix += line.Key + " = "
+		}
+
+		switch {
+		case line.Open != "":
+			text = append(text, prefix+line.Open)
+		case line.Kind == diffRemoved:
+			text = append(text, valueText(prefix, "- "+indent, line.Before)...)
+		case line.Kind != diffChanged || line.Sensitive:
+			text = append(text, valueText(prefix, line.marker()+" "+indent, line.After)...)
+		case !line.Unknown && (strings.Contains(line.Before, "\n") || strings.Contains(line.After, "\n")):
+			// Multi-line values are shown as a line diff with some context
+			text = append(text, strings.TrimRight(prefix, " "))
+			for _, textLine := range unifiedLineDiff(line.BeforeText, line.AfterText) {
+				if textLine.Skipped > 0 {
+					text = append(text, "  "+indent+"  "+textLine.skippedComment())
+					continue
+				}
+				text = append(text, textLine.marker()+" "+indent+"  "+textLine.Text)
+			}
+		default:
+			text = append(text, prefix+line.Before+" -> "+line.After)
+		}
+
+		if line.ForcesReplacement {
+			text[first] += " # forces replacement"
+		}
+	}
+
+	return text
+}
+
+// valueText renders a possibly multi-line value after prefix, starting each
+// continuation line with continuation so the diff marker is repeated
+func valueText(prefix, continuation, value string) []string {
+	lines := strings.Split(value, "\n")
+	text := []string{prefix + lines[0]}
+	for _, line := range lines[1:] {
+		text = append(text, continuation+line)
+	}
+	return text
+}
+
+// markdownCode wraps text in a code span that survives table cells
+func markdownCode(text string) string {
+	fence := "`"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	text = strings.ReplaceAll(text, "|", `\|`)
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		text = " " + text + " "
+	}
+	return fence + text + fence
+}
+
+// markdownFence returns a code fence longer than any backtick run in content
+func markdownFence(content string) string {
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	return fence
+}
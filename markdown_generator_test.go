@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"cloudvic-tf-plan-viz/plan"
+)
+
+// largePlan generates a plan with the given number of resource changes,
+// drifted resources and output changes. Every resource is a destroy-first
+// replacement of a database, so the high-risk warning lists all of them.
+func largePlan(resources, drifted, outputs int) *plan.Plan {
+	p := &plan.Plan{FormatVersion: "1.2", OutputChanges: map[string]plan.Change{}}
+
+	for i := 0; i < resources; i++ {
+		p.ResourceChanges = append(p.ResourceChanges, plan.ResourceChange{
+			Address:      fmt.Sprintf("module.databases[%d].aws_db_instance.main", i),
+			Mode:         "managed",
+			Type:         "aws_db_instance",
+			Name:         "main",
+			ProviderName: "registry.terraform.io/hashicorp/aws",
+			Change: plan.Change{
+				Actions: plan.Actions{plan.ActionDelete, plan.ActionCreate},
+				Before:  map[string]interface{}{"engine_version": "14.1", "parameters": strings.Repeat("before ", 200)},
+				After:   map[string]interface{}{"engine_version": "15.2", "parameters": strings.Repeat("after ", 200)},
+			},
+		})
+	}
+	for i := 0; i < drifted; i++ {
+		p.ResourceDrift = append(p.ResourceDrift, plan.ResourceChange{
+			Address: fmt.Sprintf("module.network[%d].aws_security_group.drifted", i),
+			Mode:    "managed",
+			Type:    "aws_security_group",
+			Name:    "drifted",
+			Change: plan.Change{
+				Actions: plan.Actions{plan.ActionUpdate},
+				Before:  map[string]interface{}{"description": "before"},
+				After:   map[string]interface{}{"description": "after"},
+			},
+		})
+	}
+	for i := 0; i < outputs; i++ {
+		p.OutputChanges[fmt.Sprintf("output_%04d", i)] = plan.Change{
+			Actions: plan.Actions{plan.ActionCreate},
+			After:   fmt.Sprintf("value-%d", i),
+		}
+	}
+
+	return p
+}
+
+// hugeValuePlan generates a small plan where the first resource changes a
+// single-line value larger than the comment size limit
+func hugeValuePlan() *plan.Plan {
+	p := largePlan(6, 0, 0)
+	p.ResourceChanges[0].Change.After.(map[string]interface{})["parameters"] = strings.Repeat("x", 70000)
+	return p
+}
+
+func TestGenerateMarkdownSizeLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		plan     *plan.Plan
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "small plan is complete",
+			plan:     largePlan(3, 2, 2),
+			contains: []string{"`module.databases[2].aws_db_instance.main`", "output_0001", "module.network[1]"},
+		},
+		{
+			name: "drift and outputs are cut",
+			plan: largePlan(50, 3000, 3000),
+			contains: []string{
+				"| replace (destroy first) :warning: **high risk** | `module.databases[49].aws_db_instance.main` |",
+				"more outputs are not listed",
+				"more drifted resources are not listed",
+				"<details><summary><code>module.databases[0].aws_db_instance.main</code>",
+			},
+		},
+		{
+			name:     "every section is cut",
+			plan:     largePlan(3000, 3000, 3000),
+			contains: []string{"more resources are not listed", "and 2", "more outputs are not listed", "more drifted resources are not listed"},
+		},
+		{
+			name: "huge value is cut short",
+			plan: hugeValuePlan(),
+			contains: []string{
+				"(value cut short, see the HTML report)",
+				"<details><summary><code>module.databases[0].aws_db_instance.main</code>",
+				"<details><summary><code>module.databases[5].aws_db_instance.main</code>",
+			},
+			excludes: []string{"were omitted"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := generateMarkdown(tt.plan, reportOptions{SortBy: sortByAddress})
+			if len(md) > markdownCommentLimit {
+				t.Errorf("markdown is %d characters, over the %d character limit", len(md), markdownCommentLimit)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(md, want) {
+					t.Errorf("markdown does not contain %q", want)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(md, unwanted) {
+					t.Errorf("markdown contains %q", unwanted)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// moduleNode is one module instance in the tree of resource changes. The
+// root node has an empty address and holds the root module's resources.
+type moduleNode struct {
+	Address   string
+	Resources []resourceEntry
+	Children  []*moduleNode
+
+	// Counts tallies the actions of every resource in this module and all
+	// of its descendants
+	Counts map[string]int
+}
+
+// buildModuleTree groups resource changes by module_address into a tree of
+// module instances. Resources keep the order they are given in; child
+// modules are ordered by address.
+func buildModuleTree(changes []resourceEntry) *moduleNode {
+	root := &moduleNode{Counts: make(map[string]int)}
+	nodes := map[string]*moduleNode{"": root}
+
+	for _, change := range changes {
+		action := change.displayActions()[0]
+		node := root
+		node.Counts[action]++
+
+		segments := moduleAddressSegments(change.moduleAddress())
+		for i := range segments {
+			address := strings.Join(segments[:i+1], ".")
+			child, ok := nodes[address]
+			if !ok {
+				child = &moduleNode{Address: address, Counts: make(map[string]int)}
+				nodes[address] = child
+				node.Children = append(node.Children, child)
+			}
+			child.Counts[action]++
+			node = child
+		}
+
+		node.Resources = append(node.Resources, change)
+	}
+
+	root.sortChildren()
+	return root
+}
+
+func (n *moduleNode) sortChildren() {
+	sort.Slice(n.Children, func(i, j int) bool {
+		return n.Children[i].Address < n.Children[j].Address
+	})
+	for _, child := range n.Children {
+		child.sortChildren()
+	}
+}
+
+// Name returns the last step of the module address, e.g. module.vpc for
+// module.network.module.vpc
+func (n *moduleNode) Name() string {
+	segments := moduleAddressSegments(n.Address)
+	if len(segments) == 0 {
+		return ""
+	}
+	return segments[len(segments)-1]
+}
+
+// moduleAddressSegments splits a module address such as
+// module.app["a.b"].module.db into its module calls. Dots inside instance
+// keys are not treated as separators.
+func moduleAddressSegments(address string) []string {
+	if address == "" {
+		return nil
+	}
+
+	var segments []string
+	start := 0
+	depth := 0
+	inQuote := false
+
+	for i := 0; i < len(address); i++ {
+		c := address[i]
+		switch {
+		case inQuote:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inQuote = false
+			}
+		case c == '"':
+			inQuote = true
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '.' && depth == 0 && strings.HasPrefix(address[i+1:], "module."):
+			segments = append(segments, address[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(segments, address[start:])
+}
+
+// moduleAddressOf returns the module part of a resource address, e.g.
+// module.app["a"].module.db for module.app["a"].module.db.aws_db_instance.main
+func moduleAddressOf(address string) string {
+	end := 0
+	for strings.HasPrefix(address[end:], "module.") {
+		i := end + len("module.")
+		for i < len(address) && address[i] != '.' && address[i] != '[' {
+			i++
+		}
+		i = skipInstanceKey(address, i)
+		end = i
+		if end < len(address) && address[end] == '.' {
+			end++
+		} else {
+			break
+		}
+	}
+	return strings.TrimSuffix(address[:end], ".")
+}
+
+// skipInstanceKey returns the position after the [key] starting at i, if
+// there is one, honouring quoted keys that contain brackets
+func skipInstanceKey(address string, i int) int {
+	if i >= len(address) || address[i] != '[' {
+		return i
+	}
+
+	inQuote := false
+	for i++; i < len(address); i++ {
+		c := address[i]
+		switch {
+		case inQuote && c == '\\':
+			i++
+		case c == '"':
+			inQuote = !inQuote
+		case !inQuote && c == ']':
+			return i + 1
+		}
+	}
+	return i
+}
+
+// orderedActionCounts returns the actions in counts in summary order,
+// followed by any other actions alphabetically, skipping zero counts
+func orderedActionCounts(counts map[string]int) []string {
+	var actions []string
+	for _, action := range summaryActions {
+		if counts[action] > 0 {
+			actions = append(actions, action)
+		}
+	}
+
+	var others []string
+	for action, count := range counts {
+		if count > 0 && !contains(summaryActions, action) {
+			others = append(others, action)
+		}
+	}
+	sort.Strings(others)
+
+	return append(actions, others...)
+}
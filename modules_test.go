@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"cloudvic-tf-plan-viz/plan"
+)
+
+func TestModuleAddressSegments(t *testing.T) {
+	tests := []struct {
+		address string
+		want    []string
+	}{
+		{``, nil},
+		{`module.a`, []string{`module.a`}},
+		{`module.a.module.b`, []string{`module.a`, `module.b`}},
+		{`module.a[0].module.b["x"]`, []string{`module.a[0]`, `module.b["x"]`}},
+		{`module.a["x.y"].module.b`, []string{`module.a["x.y"]`, `module.b`}},
+		{`module.a["x.module.y"].module.b`, []string{`module.a["x.module.y"]`, `module.b`}},
+		{`module.a["]"].module.b`, []string{`module.a["]"]`, `module.b`}},
+		{`module.a["["].module.b`, []string{`module.a["["]`, `module.b`}},
+		{`module.a["\"].module.c"].module.b`, []string{`module.a["\"].module.c"]`, `module.b`}},
+		{`module.a["\\"].module.b`, []string{`module.a["\\"]`, `module.b`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			if got := moduleAddressSegments(tt.address); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("moduleAddressSegments(%q) = %q, want %q", tt.address, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModuleAddressOf(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{`aws_instance.web`, ``},
+		{`aws_instance.web["module.a"]`, ``},
+		{`data.aws_ami.ubuntu`, ``},
+		{`module.a.aws_instance.web`, `module.a`},
+		{`module.a.data.aws_ami.ubuntu`, `module.a`},
+		{`module.a[0].module.b.aws_instance.web[1]`, `module.a[0].module.b`},
+		{`module.a["x.y"].module.b.aws_instance.web`, `module.a["x.y"].module.b`},
+		{`module.a["]"].aws_instance.web`, `module.a["]"]`},
+		{`module.a["x\"].y"].aws_instance.web`, `module.a["x\"].y"]`},
+		{`module.a["\\"].aws_instance.web`, `module.a["\\"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			if got := moduleAddressOf(tt.address); got != tt.want {
+				t.Errorf("moduleAddressOf(%q) = %q, want %q", tt.address, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildModuleTreeWithoutModuleAddress(t *testing.T) {
+	// A trimmed plan without module_address is grouped by the module part of
+	// each resource address
+	p := &plan.Plan{FormatVersion: "1.2", ResourceChanges: []plan.ResourceChange{
+		{Address: `aws_instance.root`, Change: plan.Change{Actions: plan.Actions{plan.ActionCreate}}},
+		{Address: `module.a["x.y"].module.b.aws_instance.web`, Change: plan.Change{Actions: plan.Actions{plan.ActionUpdate}}},
+		{Address: `module.a["x.y"].aws_instance.web`, Change: plan.Change{Actions: plan.Actions{plan.ActionDelete}}},
+		{Address: `module.a["]"].aws_instance.web`, Change: plan.Change{Actions: plan.Actions{plan.ActionCreate}}},
+	}}
+
+	root := buildModuleTree(extractResourceChanges(p))
+	if len(root.Resources) != 1 || root.Resources[0].Address != `aws_instance.root` {
+		t.Errorf("root module resources = %v, want aws_instance.root", root.Resources)
+	}
+
+	var modules []string
+	var walk func(node *moduleNode)
+	walk = func(node *moduleNode) {
+		for _, child := range node.Children {
+			modules = append(modules, child.Address+" "+child.Name())
+			walk(child)
+		}
+	}
+	walk(root)
+
+	want := []string{
+		`module.a["]"] module.a["]"]`,
+		`module.a["x.y"] module.a["x.y"]`,
+		`module.a["x.y"].module.b module.b`,
+	}
+	if !reflect.DeepEqual(modules, want) {
+		t.Errorf("modules = %q, want %q", modules, want)
+	}
+	if got := root.Counts["create"] + root.Counts["update"] + root.Counts["delete"]; got != 4 {
+		t.Errorf("root module counts %d changes, want 4", got)
+	}
+}
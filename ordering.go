@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Keys the resource lists in a report can be sorted by
+const (
+	sortByAddress = "address"
+	sortByModule  = "module"
+	sortByAction  = "action"
+)
+
+var sortKeys = []string{sortByAddress, sortByModule, sortByAction}
+
+// actionOrder ranks actions for sorting by action, most destructive first,
+// so the changes that need the closest review are at the top
+var actionOrder = map[string]int{
+	"delete":  0,
+	"replace": 1,
+	"update":  2,
+	"create":  3,
+	"forget":  4,
+	"read":    5,
+	"import":  6,
+	"move":    7,
+	"no-op":   8,
+}
+
+func validateSortKey(key string) error {
+	if !contains(sortKeys, key) {
+		return fmt.Errorf("unknown sort key '%s' (expected one of: address, module, action)", key)
+	}
+	return nil
+}
+
+// sortResourceChanges orders resource changes in place by the given key.
+// Ties are always broken by address and deposed key, so the result only
+// depends on the contents of the plan and never on the order it was read in.
+func sortResourceChanges(changes []resourceEntry, key string) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+
+		switch key {
+		case sortByModule:
+			if a.moduleAddress() != b.moduleAddress() {
+				return a.moduleAddress() < b.moduleAddress()
+			}
+		case sortByAction:
+			rankA, rankB := getActionRank(a), getActionRank(b)
+			if rankA != rankB {
+				return rankA < rankB
+			}
+		}
+
+		// Deposed objects share the address of the current object and are
+		// listed after it
+		if a.Address != b.Address {
+			return a.Address < b.Address
+		}
+		if a.Deposed != b.Deposed {
+			return a.Deposed < b.Deposed
+		}
+		// A plan should not list the same object twice, but if it does the
+		// entries are still ordered by their contents
+		return entryContents(a) < entryContents(b)
+	})
+}
+
+// entryContents serializes an entry for use as a last-resort sort key.
+// Object keys are marshaled in sorted order, so equal entries always
+// serialize the same way.
+func entryContents(entry resourceEntry) string {
+	data, _ := json.Marshal(entry)
+	return string(data)
+}
+
+func getActionRank(entry resourceEntry) int {
+	action := entry.displayActions()[0]
+	if rank, ok := actionOrder[action]; ok {
+		return rank
+	}
+	return len(actionOrder)
+}
+
+// sortedCountKeys returns the keys of a count map in alphabetical order
+func sortedCountKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cloudvic-tf-plan-viz/plan"
+)
+
+// TestReportsAreReproducible renders every example plan with its resource
+// changes and drift shuffled, and checks that the output is byte-for-byte
+// the same whatever order the plan lists them in
+func TestReportsAreReproducible(t *testing.T) {
+	examples, err := filepath.Glob("examples/*-plan.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixtures, err := filepath.Glob("testdata/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := append(examples, fixtures...)
+	if len(files) == 0 {
+		t.Fatal("no plans found")
+	}
+
+	random := rand.New(rand.NewSource(1))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, format := range []string{formatHTML, formatMarkdown, formatJSON} {
+			for _, sortBy := range sortKeys {
+				options := reportOptions{SortBy: sortBy, Format: format}
+
+				var first string
+				for run := 0; run < 5; run++ {
+					planData, err := plan.Parse(data)
+					if err != nil {
+						t.Fatalf("%s: %v", file, err)
+					}
+					random.Shuffle(len(planData.ResourceChanges), func(i, j int) {
+						planData.ResourceChanges[i], planData.ResourceChanges[j] = planData.ResourceChanges[j], planData.ResourceChanges[i]
+					})
+					random.Shuffle(len(planData.ResourceDrift), func(i, j int) {
+						planData.ResourceDrift[i], planData.ResourceDrift[j] = planData.ResourceDrift[j], planData.ResourceDrift[i]
+					})
+
+					output := generateReport(planData, options)
+					if run == 0 {
+						first = output
+					} else if output != first {
+						t.Errorf("%s -format %s -sort %s: output of run %d differs from the first run", file, format, sortBy, run+1)
+						break
+					}
+				}
+			}
+		}
+	}
+}
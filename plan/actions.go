@@ -0,0 +1,86 @@
+package plan
+
+// Action values Terraform uses in a change's actions list
+const (
+	ActionNoOp   = "no-op"
+	ActionCreate = "create"
+	ActionRead   = "read"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	ActionForget = "forget"
+)
+
+// Actions is the list of actions Terraform will take for a change. Most
+// changes have a single action; replacements have two, in the order
+// Terraform will perform them.
+type Actions []string
+
+// Primary returns the first action, or no-op for an empty list
+func (a Actions) Primary() string {
+	if len(a) == 0 {
+		return ActionNoOp
+	}
+	return a[0]
+}
+
+// Is reports whether the list consists of exactly the given actions
+func (a Actions) Is(actions ...string) bool {
+	if len(a) != len(actions) {
+		return false
+	}
+	for i := range a {
+		if a[i] != actions[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// NoOp reports whether nothing will change
+func (a Actions) NoOp() bool {
+	return a.Is(ActionNoOp)
+}
+
+// Create reports whether a new object will be created
+func (a Actions) Create() bool {
+	return a.Is(ActionCreate)
+}
+
+// Read reports whether a data source will be read during apply
+func (a Actions) Read() bool {
+	return a.Is(ActionRead)
+}
+
+// Update reports whether an object will be updated in place
+func (a Actions) Update() bool {
+	return a.Is(ActionUpdate)
+}
+
+// Delete reports whether an object will be destroyed
+func (a Actions) Delete() bool {
+	return a.Is(ActionDelete)
+}
+
+// Forget reports whether an object will be removed from state without
+// being destroyed
+func (a Actions) Forget() bool {
+	return a.Is(ActionForget)
+}
+
+// Replace reports whether an object will be destroyed and recreated, in
+// either order
+func (a Actions) Replace() bool {
+	return a.CreateBeforeDestroy() || a.DestroyBeforeCreate()
+}
+
+// CreateBeforeDestroy reports whether the replacement object is created
+// before the existing one is destroyed
+func (a Actions) CreateBeforeDestroy() bool {
+	return a.Is(ActionCreate, ActionDelete)
+}
+
+// DestroyBeforeCreate reports whether the existing object is destroyed
+// before its replacement is created
+func (a Actions) DestroyBeforeCreate() bool {
+	return a.Is(ActionDelete, ActionCreate)
+}
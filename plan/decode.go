@@ -0,0 +1,95 @@
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// supportedFormatMajors are the major versions of the JSON plan format this
+// package understands. Terraform releases before 1.1 write 0.1 or 0.2, which
+// the 1.x format only extends, and later releases only bump the minor version
+// for backwards-compatible additions.
+var supportedFormatMajors = map[string]bool{"0": true, "1": true}
+
+// Parse decodes a JSON plan and checks that it is a plan in a format version
+// this package supports.
+func Parse(data []byte) (*Plan, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("plan JSON is empty")
+	}
+	if trimmed[0] != '{' {
+		return nil, fmt.Errorf("plan JSON must be an object")
+	}
+
+	// `terraform show -json` on a state file produces a similar document
+	// with "values" instead of "planned_values"
+	var probe struct {
+		Values        json.RawMessage `json:"values"`
+		PlannedValues json.RawMessage `json:"planned_values"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("parsing plan JSON: %v", err)
+	}
+	if probe.Values != nil && probe.PlannedValues == nil {
+		return nil, fmt.Errorf("input is a Terraform state, not a plan; run `terraform show -json` on a saved plan file")
+	}
+
+	var p Plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parsing plan JSON: %v", err)
+	}
+
+	if err := checkFormatVersion(p.FormatVersion); err != nil {
+		return nil, err
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func checkFormatVersion(version string) error {
+	if version == "" {
+		return fmt.Errorf("plan JSON has no format_version; expected the output of `terraform show -json <planfile>`")
+	}
+	major, _, _ := strings.Cut(version, ".")
+	if !supportedFormatMajors[major] {
+		return fmt.Errorf("unsupported plan format_version %q (supported: 0.x and 1.x); the plan may come from a newer Terraform release than this tool supports", version)
+	}
+	return nil
+}
+
+// validate checks the fields the rest of the format hangs off, so a plan
+// that decodes but is missing them is reported instead of rendered empty.
+func (p *Plan) validate() error {
+	for i, rc := range p.ResourceChanges {
+		if err := rc.validate(); err != nil {
+			return fmt.Errorf("resource_changes[%d]: %v", i, err)
+		}
+	}
+	for i, rc := range p.ResourceDrift {
+		if err := rc.validate(); err != nil {
+			return fmt.Errorf("resource_drift[%d]: %v", i, err)
+		}
+	}
+	for name, change := range p.OutputChanges {
+		if len(change.Actions) == 0 {
+			return fmt.Errorf("output_changes[%q]: change has no actions", name)
+		}
+	}
+	return nil
+}
+
+func (rc ResourceChange) validate() error {
+	if rc.Address == "" {
+		return fmt.Errorf("resource change has no address")
+	}
+	if len(rc.Change.Actions) == 0 {
+		return fmt.Errorf("%s: change has no actions", rc.Address)
+	}
+	return nil
+}
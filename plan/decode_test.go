@@ -0,0 +1,94 @@
+package plan
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name:  "current format",
+			input: `{"format_version": "1.2", "resource_changes": [{"address": "aws_instance.a", "change": {"actions": ["create"]}}]}`,
+		},
+		{
+			name:  "format written before Terraform 1.1",
+			input: `{"format_version": "0.2", "resource_changes": [{"address": "aws_instance.a", "change": {"actions": ["no-op"]}}]}`,
+		},
+		{
+			name:    "empty input",
+			input:   " \n",
+			wantErr: "plan JSON is empty",
+		},
+		{
+			name:    "not an object",
+			input:   `[{"format_version": "1.2"}]`,
+			wantErr: "plan JSON must be an object",
+		},
+		{
+			name:    "invalid JSON",
+			input:   `{"format_version": "1.2",`,
+			wantErr: "parsing plan JSON",
+		},
+		{
+			name:    "state instead of a plan",
+			input:   `{"format_version": "1.0", "values": {"root_module": {}}}`,
+			wantErr: "input is a Terraform state, not a plan",
+		},
+		{
+			name:    "missing format_version",
+			input:   `{"resource_changes": []}`,
+			wantErr: "plan JSON has no format_version",
+		},
+		{
+			name:    "unsupported format_version",
+			input:   `{"format_version": "2.0"}`,
+			wantErr: `unsupported plan format_version "2.0"`,
+		},
+		{
+			name:    "resource change without an address",
+			input:   `{"format_version": "1.2", "resource_changes": [{"change": {"actions": ["create"]}}]}`,
+			wantErr: "resource_changes[0]: resource change has no address",
+		},
+		{
+			name:    "resource change without actions",
+			input:   `{"format_version": "1.2", "resource_changes": [{"address": "aws_instance.a", "change": {}}]}`,
+			wantErr: "resource_changes[0]: aws_instance.a: change has no actions",
+		},
+		{
+			name:    "drift without actions",
+			input:   `{"format_version": "1.2", "resource_drift": [{"address": "aws_instance.a", "change": {"actions": []}}]}`,
+			wantErr: "resource_drift[0]: aws_instance.a: change has no actions",
+		},
+		{
+			name:    "output change without actions",
+			input:   `{"format_version": "1.2", "output_changes": {"url": {"after": "x"}}}`,
+			wantErr: `output_changes["url"]: change has no actions`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse([]byte(tt.input))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Parse() error = %v", err)
+				}
+				if p == nil {
+					t.Fatal("Parse() returned no plan")
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Parse() succeeded, want error containing %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
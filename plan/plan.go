@@ -0,0 +1,223 @@
+// Package plan models the JSON representation of a Terraform plan, as
+// produced by `terraform show -json <planfile>`.
+//
+// Attribute values (before, after, planned values and so on) depend on each
+// provider's schema, so they are kept as the generic values encoding/json
+// produces: map[string]interface{}, []interface{}, string, float64, bool
+// and nil. Everything else in the format has a fixed shape and is typed.
+package plan
+
+// Plan is the top-level object of a JSON plan
+type Plan struct {
+	FormatVersion      string              `json:"format_version"`
+	TerraformVersion   string              `json:"terraform_version,omitempty"`
+	Variables          map[string]Variable `json:"variables,omitempty"`
+	PlannedValues      *Values             `json:"planned_values,omitempty"`
+	ResourceDrift      []ResourceChange    `json:"resource_drift,omitempty"`
+	ResourceChanges    []ResourceChange    `json:"resource_changes,omitempty"`
+	OutputChanges      map[string]Change   `json:"output_changes,omitempty"`
+	PriorState         *State              `json:"prior_state,omitempty"`
+	Configuration      *Configuration      `json:"configuration,omitempty"`
+	RelevantAttributes []ResourceAttribute `json:"relevant_attributes,omitempty"`
+	Checks             []CheckResult       `json:"checks,omitempty"`
+	Timestamp          string              `json:"timestamp,omitempty"`
+	Applyable          bool                `json:"applyable"`
+	Complete           bool                `json:"complete"`
+	Errored            bool                `json:"errored"`
+}
+
+// Variable is the value of an input variable the plan was created with
+type Variable struct {
+	Value interface{} `json:"value"`
+}
+
+// Values describes resources and outputs at a single point in time, either
+// as planned (planned_values) or as recorded in state (prior_state)
+type Values struct {
+	Outputs    map[string]Output `json:"outputs,omitempty"`
+	RootModule Module            `json:"root_module"`
+}
+
+// Output is a root module output value
+type Output struct {
+	Sensitive bool        `json:"sensitive"`
+	Type      interface{} `json:"type,omitempty"`
+	Value     interface{} `json:"value,omitempty"`
+}
+
+// Module holds the resources of one module instance and its children
+type Module struct {
+	Address      string     `json:"address,omitempty"`
+	Resources    []Resource `json:"resources,omitempty"`
+	ChildModules []Module   `json:"child_modules,omitempty"`
+}
+
+// Resource is a resource instance inside planned values or state
+type Resource struct {
+	Address         string                 `json:"address"`
+	Mode            string                 `json:"mode"`
+	Type            string                 `json:"type"`
+	Name            string                 `json:"name"`
+	Index           interface{}            `json:"index,omitempty"`
+	ProviderName    string                 `json:"provider_name"`
+	SchemaVersion   int                    `json:"schema_version"`
+	Values          map[string]interface{} `json:"values,omitempty"`
+	SensitiveValues interface{}            `json:"sensitive_values,omitempty"`
+	DependsOn       []string               `json:"depends_on,omitempty"`
+	Tainted         bool                   `json:"tainted,omitempty"`
+	DeposedKey      string                 `json:"deposed_key,omitempty"`
+}
+
+// State is the prior state the plan was computed against
+type State struct {
+	FormatVersion    string  `json:"format_version,omitempty"`
+	TerraformVersion string  `json:"terraform_version,omitempty"`
+	Values           *Values `json:"values,omitempty"`
+}
+
+// ResourceChange describes the planned change to one resource instance, or
+// in resource_drift a change Terraform detected outside of its control
+type ResourceChange struct {
+	Address         string      `json:"address"`
+	PreviousAddress string      `json:"previous_address,omitempty"`
+	ModuleAddress   string      `json:"module_address,omitempty"`
+	Mode            string      `json:"mode"`
+	Type            string      `json:"type"`
+	Name            string      `json:"name"`
+	Index           interface{} `json:"index,omitempty"`
+	ProviderName    string      `json:"provider_name"`
+	Deposed         string      `json:"deposed,omitempty"`
+	Change          Change      `json:"change"`
+	ActionReason    string      `json:"action_reason,omitempty"`
+}
+
+// Change is the before and after representation of a resource or output.
+// The sensitive and unknown fields mirror the shape of the values they
+// describe, with true marking a sensitive or unknown part.
+type Change struct {
+	Actions         Actions         `json:"actions"`
+	Before          interface{}     `json:"before"`
+	After           interface{}     `json:"after"`
+	AfterUnknown    interface{}     `json:"after_unknown,omitempty"`
+	BeforeSensitive interface{}     `json:"before_sensitive,omitempty"`
+	AfterSensitive  interface{}     `json:"after_sensitive,omitempty"`
+	ReplacePaths    [][]interface{} `json:"replace_paths,omitempty"`
+	Importing       *Importing      `json:"importing,omitempty"`
+	GeneratedConfig string          `json:"generated_config,omitempty"`
+}
+
+// Importing is present on a change when the resource is being imported by
+// an import block
+type Importing struct {
+	ID       string      `json:"id,omitempty"`
+	Unknown  bool        `json:"unknown,omitempty"`
+	Identity interface{} `json:"identity,omitempty"`
+}
+
+// ResourceAttribute is an attribute whose value contributed to the plan,
+// listed in relevant_attributes
+type ResourceAttribute struct {
+	Resource  string        `json:"resource"`
+	Attribute []interface{} `json:"attribute"`
+}
+
+// Configuration is the static configuration the plan was created from
+type Configuration struct {
+	ProviderConfig map[string]ProviderConfig `json:"provider_config,omitempty"`
+	RootModule     ConfigModule              `json:"root_module"`
+}
+
+// ProviderConfig is a provider block in the configuration
+type ProviderConfig struct {
+	Name              string                 `json:"name"`
+	FullName          string                 `json:"full_name,omitempty"`
+	Alias             string                 `json:"alias,omitempty"`
+	ModuleAddress     string                 `json:"module_address,omitempty"`
+	VersionConstraint string                 `json:"version_constraint,omitempty"`
+	Expressions       map[string]interface{} `json:"expressions,omitempty"`
+}
+
+// ConfigModule is the configuration of one module
+type ConfigModule struct {
+	Outputs     map[string]ConfigOutput   `json:"outputs,omitempty"`
+	Resources   []ConfigResource          `json:"resources,omitempty"`
+	ModuleCalls map[string]ModuleCall     `json:"module_calls,omitempty"`
+	Variables   map[string]ConfigVariable `json:"variables,omitempty"`
+}
+
+// ConfigResource is a resource or data block in the configuration
+type ConfigResource struct {
+	Address           string                 `json:"address"`
+	Mode              string                 `json:"mode"`
+	Type              string                 `json:"type"`
+	Name              string                 `json:"name"`
+	ProviderConfigKey string                 `json:"provider_config_key,omitempty"`
+	Expressions       map[string]interface{} `json:"expressions,omitempty"`
+	SchemaVersion     int                    `json:"schema_version"`
+	CountExpression   interface{}            `json:"count_expression,omitempty"`
+	ForEachExpression interface{}            `json:"for_each_expression,omitempty"`
+	DependsOn         []string               `json:"depends_on,omitempty"`
+}
+
+// ModuleCall is a module block in the configuration
+type ModuleCall struct {
+	Source            string                 `json:"source"`
+	Expressions       map[string]interface{} `json:"expressions,omitempty"`
+	CountExpression   interface{}            `json:"count_expression,omitempty"`
+	ForEachExpression interface{}            `json:"for_each_expression,omitempty"`
+	Module            ConfigModule           `json:"module"`
+	VersionConstraint string                 `json:"version_constraint,omitempty"`
+	DependsOn         []string               `json:"depends_on,omitempty"`
+}
+
+// ConfigOutput is an output block in the configuration
+type ConfigOutput struct {
+	Expression  interface{} `json:"expression,omitempty"`
+	Sensitive   bool        `json:"sensitive,omitempty"`
+	Description string      `json:"description,omitempty"`
+	DependsOn   []string    `json:"depends_on,omitempty"`
+}
+
+// ConfigVariable is a variable block in the configuration
+type ConfigVariable struct {
+	Default     interface{} `json:"default,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Sensitive   bool        `json:"sensitive,omitempty"`
+}
+
+// CheckResult is the status of one checkable object, such as a check block,
+// a resource with preconditions or an output with postconditions
+type CheckResult struct {
+	Address   CheckAddress    `json:"address"`
+	Status    string          `json:"status"`
+	Instances []CheckInstance `json:"instances,omitempty"`
+}
+
+// CheckAddress identifies the object a check result belongs to
+type CheckAddress struct {
+	Kind      string `json:"kind"`
+	ToDisplay string `json:"to_display"`
+	Mode      string `json:"mode,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Module    string `json:"module,omitempty"`
+}
+
+// CheckInstance is the status of one instance of a checkable object
+type CheckInstance struct {
+	Address  CheckInstanceAddress `json:"address"`
+	Status   string               `json:"status"`
+	Problems []CheckProblem       `json:"problems,omitempty"`
+}
+
+// CheckInstanceAddress identifies one instance of a checkable object
+type CheckInstanceAddress struct {
+	ToDisplay   string      `json:"to_display"`
+	Module      string      `json:"module,omitempty"`
+	InstanceKey interface{} `json:"instance_key,omitempty"`
+}
+
+// CheckProblem is a failed condition reported by a check
+type CheckProblem struct {
+	Message string `json:"message"`
+}
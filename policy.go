@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cloudvic-tf-plan-viz/plan"
+)
+
+// policyStatement is one statement of an IAM policy document, with every
+// element rendered as a sorted list of display values. NotPrincipal,
+// NotAction and NotResource share a column with their positive form and are
+// prefixed with "NOT".
+type policyStatement struct {
+	Sid       string
+	Effect    string
+	Principal []string
+	Action    []string
+	Resource  []string
+	Condition []string
+}
+
+// policyStatementChange is one row of a policy diff. Before is nil for
+// added statements and After is nil for removed ones.
+type policyStatementChange struct {
+	Kind   diffKind
+	Label  string
+	Before *policyStatement
+	After  *policyStatement
+}
+
+// policyDiff compares the statements of a policy document held in a string
+// attribute, such as policy or assume_role_policy
+type policyDiff struct {
+	Path       string
+	Statements []policyStatementChange
+	Warnings   []string
+}
+
+// changePolicyDiffs finds the changed attributes of a resource change that
+// hold IAM policy documents and compares them statement by statement.
+// Sensitive and unknown policies are left to the attribute diff.
+func changePolicyDiffs(change plan.Change) []policyDiff {
+	_, beforeOk := change.Before.(map[string]interface{})
+	_, afterOk := change.After.(map[string]interface{})
+	if !beforeOk && !afterOk {
+		return nil
+	}
+
+	root := diffValues("", change.Before, change.After, change.BeforeSensitive, change.AfterSensitive, change.AfterUnknown)
+	return root.policyDiffs("")
+}
+
+func (n *diffNode) policyDiffs(path string) []policyDiff {
+	if n.Kind == diffUnchanged || n.Sensitive {
+		return nil
+	}
+
+	if n.IsJSON {
+		before, _ := decodeJSONString(n.Before)
+		after, _ := decodeJSONString(n.After)
+		if isPolicyDocument(before) || isPolicyDocument(after) {
+			return []policyDiff{diffPolicies(path, before, after)}
+		}
+		return nil
+	}
+
+	var diffs []policyDiff
+	for _, child := range n.Children {
+		childPath := child.Key
+		switch {
+		case n.IsList:
+			childPath = path + "[" + child.Key + "]"
+		case path != "":
+			childPath = path + "." + child.Key
+		}
+		diffs = append(diffs, child.policyDiffs(childPath)...)
+	}
+	return diffs
+}
+
+// isPolicyDocument reports whether a decoded JSON value is shaped like an
+// IAM policy: an object with a Statement that is an object or a list
+func isPolicyDocument(value interface{}) bool {
+	doc, ok := value.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	switch doc["Statement"].(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// diffPolicies pairs up the statements of two policy documents. Statements
+// that are identical apart from ordering are unchanged, and statements with
+// the same Sid are changed. Statements without a Sid are changed when they
+// have the same Effect and Resource, or failing that the same position in
+// the document. The rest are added or removed.
+func diffPolicies(path string, before, after interface{}) policyDiff {
+	beforeStatements := policyStatements(before)
+	afterStatements := policyStatements(after)
+
+	used := make([]bool, len(beforeStatements))
+	match := func(matches func(policyStatement) bool) int {
+		for i, statement := range beforeStatements {
+			if !used[i] && matches(statement) {
+				used[i] = true
+				return i
+			}
+		}
+		return -1
+	}
+
+	diff := policyDiff{Path: path}
+	pairs := make([]int, len(afterStatements))
+	for i, statement := range afterStatements {
+		pairs[i] = match(statement.equal)
+	}
+	for i, statement := range afterStatements {
+		if pairs[i] < 0 && statement.Sid != "" {
+			pairs[i] = match(func(other policyStatement) bool { return other.Sid == statement.Sid })
+		}
+	}
+	for i, statement := range afterStatements {
+		if pairs[i] < 0 && statement.Sid == "" {
+			pairs[i] = match(func(other policyStatement) bool {
+				return other.Sid == "" && other.Effect == statement.Effect && stringsEqual(other.Resource, statement.Resource)
+			})
+		}
+	}
+	for i, statement := range afterStatements {
+		if pairs[i] < 0 && statement.Sid == "" && i < len(beforeStatements) && !used[i] && beforeStatements[i].Sid == "" {
+			used[i] = true
+			pairs[i] = i
+		}
+	}
+
+	for i := range afterStatements {
+		statement := afterStatements[i]
+		row := policyStatementChange{Kind: diffAdded, Label: statement.label(i), After: &statement}
+		if pairs[i] >= 0 {
+			row.Before = &beforeStatements[pairs[i]]
+			row.Kind = diffChanged
+			if row.Before.equal(statement) {
+				row.Kind = diffUnchanged
+			}
+		}
+		if row.Kind != diffUnchanged {
+			diff.Warnings = append(diff.Warnings, statement.wildcardWarnings(path, row.Label)...)
+		}
+		diff.Statements = append(diff.Statements, row)
+	}
+	for i := range beforeStatements {
+		if !used[i] {
+			diff.Statements = append(diff.Statements, policyStatementChange{
+				Kind:   diffRemoved,
+				Label:  beforeStatements[i].label(i),
+				Before: &beforeStatements[i],
+			})
+		}
+	}
+
+	return diff
+}
+
+// policyStatements returns the statements of a policy document. A single
+// statement may be given as an object instead of a list.
+func policyStatements(doc interface{}) []policyStatement {
+	docMap, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	var raw []interface{}
+	switch statements := docMap["Statement"].(type) {
+	case map[string]interface{}:
+		raw = []interface{}{statements}
+	case []interface{}:
+		raw = statements
+	}
+
+	var statements []policyStatement
+	for _, item := range raw {
+		if statement, ok := item.(map[string]interface{}); ok {
+			statements = append(statements, newPolicyStatement(statement))
+		}
+	}
+	return statements
+}
+
+func newPolicyStatement(statement map[string]interface{}) policyStatement {
+	sid, _ := statement["Sid"].(string)
+	effect, _ := statement["Effect"].(string)
+
+	return policyStatement{
+		Sid:       sid,
+		Effect:    effect,
+		Principal: append(policyPrincipals(statement["Principal"], ""), policyPrincipals(statement["NotPrincipal"], "NOT ")...),
+		Action:    append(policyValues(statement["Action"], ""), policyValues(statement["NotAction"], "NOT ")...),
+		Resource:  append(policyValues(statement["Resource"], ""), policyValues(statement["NotResource"], "NOT ")...),
+		Condition: policyConditions(statement["Condition"]),
+	}
+}
+
+// policyValues renders an element that holds a string or a list of strings
+func policyValues(value interface{}, prefix string) []string {
+	var values []string
+	switch v := value.(type) {
+	case string:
+		values = append(values, prefix+v)
+	case []interface{}:
+		for _, item := range v {
+			values = append(values, prefix+fmt.Sprintf("%v", item))
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// policyPrincipals renders a Principal element, which is either "*" or an
+// object mapping principal types such as AWS or Service to identifiers
+func policyPrincipals(value interface{}, prefix string) []string {
+	principals, ok := value.(map[string]interface{})
+	if !ok {
+		return policyValues(value, prefix)
+	}
+
+	var values []string
+	for _, principalType := range sortedUnionKeys(principals) {
+		for _, principal := range policyValues(principals[principalType], "") {
+			values = append(values, prefix+principalType+": "+principal)
+		}
+	}
+	return values
+}
+
+// policyConditions renders a Condition element as one line per operator
+func policyConditions(value interface{}) []string {
+	conditions, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	var values []string
+	for _, operator := range sortedUnionKeys(conditions) {
+		// Conditions are small objects, so they read best on one line
+		condition, err := json.Marshal(conditions[operator])
+		if err != nil {
+			condition = []byte(fmt.Sprintf("%v", conditions[operator]))
+		}
+		values = append(values, operator+": "+string(condition))
+	}
+	return values
+}
+
+// label identifies a statement by its Sid, or by its position when it has none
+func (s policyStatement) label(index int) string {
+	if s.Sid != "" {
+		return s.Sid
+	}
+	return fmt.Sprintf("#%d", index+1)
+}
+
+// columns returns the elements shown in a policy table, in column order
+func (s policyStatement) columns() [][]string {
+	var effect []string
+	if s.Effect != "" {
+		effect = []string{s.Effect}
+	}
+	return [][]string{effect, s.Principal, s.Action, s.Resource, s.Condition}
+}
+
+func (s policyStatement) equal(other policyStatement) bool {
+	return s.Sid == other.Sid &&
+		s.Effect == other.Effect &&
+		stringsEqual(s.Principal, other.Principal) &&
+		stringsEqual(s.Action, other.Action) &&
+		stringsEqual(s.Resource, other.Resource) &&
+		stringsEqual(s.Condition, other.Condition)
+}
+
+// wildcardWarnings flags Allow statements that grant every action, every
+// action of a service or access to any principal
+func (s policyStatement) wildcardWarnings(path, label string) []string {
+	if s.Effect != "Allow" {
+		return nil
+	}
+
+	var warnings []string
+	for _, action := range s.Action {
+		switch {
+		case action == "*":
+			warnings = append(warnings, fmt.Sprintf(`Statement %s in %s allows every action ("*").`, label, path))
+		case strings.HasSuffix(action, ":*") && !strings.HasPrefix(action, "NOT "):
+			warnings = append(warnings, fmt.Sprintf(`Statement %s in %s allows every %s action ("%s").`,
+				label, path, strings.TrimSuffix(action, ":*"), action))
+		}
+	}
+	for _, principal := range s.Principal {
+		if principal == "*" || strings.HasSuffix(principal, ": *") && !strings.HasPrefix(principal, "NOT ") {
+			warnings = append(warnings, fmt.Sprintf(`Statement %s in %s allows any principal ("*").`, label, path))
+			break
+		}
+	}
+	return warnings
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDiffPolicies(t *testing.T) {
+	getObject := map[string]interface{}{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::b/*"}
+	putObject := map[string]interface{}{"Effect": "Allow", "Action": "s3:PutObject", "Resource": "arn:aws:s3:::b/*"}
+	logs := map[string]interface{}{"Effect": "Allow", "Action": "logs:PutLogEvents", "Resource": "*"}
+	denyDelete := map[string]interface{}{"Effect": "Deny", "Action": "s3:DeleteBucket", "Resource": "arn:aws:s3:::b"}
+	everything := map[string]interface{}{"Effect": "Allow", "Action": "*", "Principal": map[string]interface{}{"AWS": "*"}, "Resource": "*"}
+	withSid := func(statement map[string]interface{}, sid string) map[string]interface{} {
+		copied := map[string]interface{}{"Sid": sid}
+		for key, value := range statement {
+			copied[key] = value
+		}
+		return copied
+	}
+
+	tests := []struct {
+		name          string
+		before, after []interface{}
+		kinds         []diffKind
+		changedCells  []int
+		warnings      []string
+	}{
+		{
+			name:   "reordered statements are unchanged",
+			before: []interface{}{getObject, logs},
+			after:  []interface{}{logs, getObject},
+			kinds:  []diffKind{diffUnchanged, diffUnchanged},
+		},
+		{
+			name:         "statements with the same Sid are changed",
+			before:       []interface{}{withSid(getObject, "Read"), logs},
+			after:        []interface{}{logs, withSid(putObject, "Read")},
+			kinds:        []diffKind{diffUnchanged, diffChanged},
+			changedCells: []int{2},
+		},
+		{
+			name:         "statements without a Sid are paired by Effect and Resource",
+			before:       []interface{}{logs, getObject},
+			after:        []interface{}{putObject, logs},
+			kinds:        []diffKind{diffChanged, diffUnchanged},
+			changedCells: []int{2},
+		},
+		{
+			name:         "statements without a Sid are paired by position",
+			before:       []interface{}{getObject},
+			after:        []interface{}{denyDelete},
+			kinds:        []diffKind{diffChanged},
+			changedCells: []int{0, 2, 3},
+		},
+		{
+			name:     "wildcards in new statements are flagged",
+			before:   []interface{}{withSid(getObject, "Read")},
+			after:    []interface{}{withSid(getObject, "Read"), everything},
+			kinds:    []diffKind{diffUnchanged, diffAdded},
+			warnings: []string{`allows every action ("*")`, `allows any principal ("*")`},
+		},
+		{
+			name:   "removed statements are listed last",
+			before: []interface{}{withSid(getObject, "Read"), withSid(logs, "Logs")},
+			after:  []interface{}{withSid(logs, "Logs")},
+			kinds:  []diffKind{diffUnchanged, diffRemoved},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := diffPolicies("policy", decodePolicyFixture(t, tt.before), decodePolicyFixture(t, tt.after))
+
+			var kinds []diffKind
+			for _, statement := range diff.Statements {
+				kinds = append(kinds, statement.Kind)
+			}
+			if !equalKinds(kinds, tt.kinds) {
+				t.Fatalf("statement kinds = %v, want %v", kinds, tt.kinds)
+			}
+
+			var changedCells []int
+			for _, row := range newPolicyRows(diff) {
+				for i, cell := range row.Cells {
+					if cell.Changed {
+						changedCells = append(changedCells, i)
+					}
+				}
+			}
+			if len(changedCells) != len(tt.changedCells) {
+				t.Errorf("changed cells = %v, want %v", changedCells, tt.changedCells)
+			} else {
+				for i := range changedCells {
+					if changedCells[i] != tt.changedCells[i] {
+						t.Errorf("changed cells = %v, want %v", changedCells, tt.changedCells)
+						break
+					}
+				}
+			}
+
+			if len(diff.Warnings) != len(tt.warnings) {
+				t.Fatalf("warnings = %q, want %d", diff.Warnings, len(tt.warnings))
+			}
+			for i, want := range tt.warnings {
+				if !strings.Contains(diff.Warnings[i], want) {
+					t.Errorf("warning %q does not contain %q", diff.Warnings[i], want)
+				}
+			}
+		})
+	}
+}
+
+// decodePolicyFixture round-trips statements through a JSON policy string,
+// the way they arrive in a plan
+func decodePolicyFixture(t *testing.T, statements []interface{}) interface{} {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]interface{}{"Version": "2012-10-17", "Statement": statements})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, ok := decodeJSONString(string(data))
+	if !ok {
+		t.Fatalf("policy did not decode: %s", data)
+	}
+	return doc
+}
+
+func equalKinds(a, b []diffKind) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
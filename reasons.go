@@ -0,0 +1,85 @@
+package main
+
+import "fmt"
+
+// actionReasons explains the action_reason values Terraform attaches to a
+// change in plain language
+var actionReasons = map[string]string{
+	"read_because_config_unknown":     "The data source's configuration depends on values that are not known until apply, so it will be read during apply.",
+	"read_because_dependency_pending": "The data source depends on a managed resource with pending changes, so it will be read after that change is applied.",
+	"read_because_check_nested":       "The data source is declared inside a check block and is always read during apply.",
+
+	"replace_because_tainted":       "The object is marked as tainted, usually because a previous apply failed part way through creating it, so it will be replaced.",
+	"replace_because_cannot_update": "The provider cannot update some of the changed attributes in place, so the object must be replaced.",
+	"replace_by_request":            "Replacement was requested explicitly, for example with -replace.",
+	"replace_by_triggers":           "A value in the resource's replace_triggered_by list changed, so it will be replaced.",
+
+	"delete_because_no_resource_config": "The resource block was removed from the configuration.",
+	"delete_because_no_module":          "The module containing this resource was removed from the configuration.",
+	"delete_because_wrong_repetition":   "The resource switched between count, for_each and a single instance, so the instance under the old key is destroyed.",
+	"delete_because_count_index":        "The resource's count was reduced, so this index is no longer part of the configuration.",
+	"delete_because_each_key":           "This key was removed from the resource's for_each value.",
+	"delete_because_no_move_target":     "A moved block points at this object, but its new address is not in the configuration.",
+}
+
+// forgetDescription explains the forget action, which is easily mistaken
+// for a delete
+const forgetDescription = "Terraform will stop managing this object and remove it from state, usually because of a removed block with destroy = false. The object itself is not destroyed and keeps running in the cloud."
+
+// describeActionReason returns the explanation for an action_reason. Reasons
+// added by newer Terraform versions are shown as they are.
+func describeActionReason(reason string) string {
+	if reason == "" {
+		return ""
+	}
+	if description, ok := actionReasons[reason]; ok {
+		return description
+	}
+	return "Reason: " + reason
+}
+
+// explainChange returns why Terraform plans the entry's action, if the plan
+// says so. Resources deleted outside of Terraform carry no action_reason but
+// are shown as replacements, so they get their own explanation.
+func explainChange(change resourceEntry) string {
+	if change.ActionReason != "" {
+		return describeActionReason(change.ActionReason)
+	}
+	if change.IsReplace && change.Change.Actions.Create() {
+		return "The object was deleted outside of Terraform, so it will be created again."
+	}
+	return ""
+}
+
+// describeImport explains what happens to an object imported by an import
+// block. Any diff shown with it is drift between the imported object and
+// the configuration.
+func describeImport(change resourceEntry) string {
+	var description string
+	switch {
+	case change.importID() == "":
+		description = "Imported into Terraform."
+	case change.Change.Importing.ID == "" && change.Change.Importing.Identity != nil:
+		description = fmt.Sprintf("Imported with identity %s.", change.importID())
+	default:
+		description = fmt.Sprintf("Imported with ID %s.", change.importID())
+	}
+
+	actions := change.Change.Actions
+	switch {
+	case actions.NoOp():
+		return description + " The imported object already matches the configuration."
+	case actions.Update():
+		return description + " The imported object differs from the configuration, so Terraform will update it in place after importing it."
+	case actions.Replace():
+		return description + " The imported object cannot be updated to match the configuration, so it will be replaced after importing it."
+	default:
+		return description
+	}
+}
+
+// describeDeposed explains a deposed object, which shares its address with
+// the current object of the same resource
+func describeDeposed(change resourceEntry) string {
+	return fmt.Sprintf("Deposed object %s is left over from an earlier create-before-destroy replacement: the new object was created but the apply failed before the old one was destroyed. Terraform will now finish the cleanup.", change.Deposed)
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"cloudvic-tf-plan-viz/plan"
+)
+
+// resourceEntry is a resource change as the report presents it
+type resourceEntry struct {
+	plan.ResourceChange
+
+	// IsReplace is set for replacements, including resources that were
+	// deleted outside of Terraform and are now being created again
+	IsReplace bool
+}
+
+// displayActions returns the actions shown on the entry's badges
+func (e resourceEntry) displayActions() []string {
+	if e.IsReplace {
+		return []string{"replace"}
+	}
+	if e.Change.Actions.NoOp() && e.isImport() {
+		return []string{categoryImport}
+	}
+	if e.Change.Actions.NoOp() && e.isMove() {
+		return []string{categoryMove}
+	}
+	return e.Change.Actions
+}
+
+// isImport reports whether an import block brings an existing object under
+// Terraform's management in this plan
+func (e resourceEntry) isImport() bool {
+	return e.Change.Importing != nil
+}
+
+// importID returns how the imported object is identified: its import ID, or
+// its resource identity for providers that import by identity
+func (e resourceEntry) importID() string {
+	importing := e.Change.Importing
+	switch {
+	case importing == nil:
+		return ""
+	case importing.ID != "":
+		return importing.ID
+	case importing.Identity != nil:
+		// Identities are small objects, so they read best on one line
+		identity, err := json.Marshal(importing.Identity)
+		if err != nil {
+			return fmt.Sprintf("%v", importing.Identity)
+		}
+		return string(identity)
+	case importing.Unknown:
+		return unknownPlaceholder
+	default:
+		return ""
+	}
+}
+
+// isMove reports whether the resource moved to a new address, because of a
+// moved block or a change of module or instance key
+func (e resourceEntry) isMove() bool {
+	return e.PreviousAddress != "" && e.PreviousAddress != e.Address
+}
+
+// Orders a replacement can happen in
+const (
+	replaceCreateFirst  = "create first"
+	replaceDestroyFirst = "destroy first"
+)
+
+// replaceOrder returns whether a replacement creates the new object or
+// destroys the old one first. Resources deleted outside of Terraform have
+// nothing left to destroy, so they have no order.
+func (e resourceEntry) replaceOrder() string {
+	switch {
+	case e.Change.Actions.CreateBeforeDestroy():
+		return replaceCreateFirst
+	case e.Change.Actions.DestroyBeforeCreate():
+		return replaceDestroyFirst
+	default:
+		return ""
+	}
+}
+
+// actionLabel returns the action shown for the entry, including the order of
+// a replacement
+func (e resourceEntry) actionLabel() string {
+	action := e.displayActions()[0]
+	if order := e.replaceOrder(); order != "" {
+		return action + " (" + order + ")"
+	}
+	return action
+}
+
+// isDataSourceRead reports whether the entry is a data source Terraform
+// will read during apply rather than while planning
+func (e resourceEntry) isDataSourceRead() bool {
+	return e.Mode == "data" && e.Change.Actions.Read()
+}
+
+// moduleAddress returns the module instance the resource belongs to. Plans
+// always carry module_address for module resources, but hand-written or
+// trimmed plans may not, so it falls back to the prefix of the address.
+func (e resourceEntry) moduleAddress() string {
+	if e.ModuleAddress != "" {
+		return e.ModuleAddress
+	}
+	return moduleAddressOf(e.Address)
+}
+
+// Categories a resource change can be counted under in the summary besides
+// its action: an import or move can accompany any other action
+const (
+	categoryImport = "import"
+	categoryMove   = "move"
+)
+
+// summaryCategories lists the categories of the summary dashboard, in the
+// order the cards are shown
+var summaryCategories = []string{"create", "update", "delete", "replace", "read", categoryImport, categoryMove, plan.ActionForget}
+
+// categories returns every summary category the entry is counted under
+func (e resourceEntry) categories() []string {
+	categories := []string{e.displayActions()[0]}
+	if e.isImport() && !contains(categories, categoryImport) {
+		categories = append(categories, categoryImport)
+	}
+	if e.isMove() && !contains(categories, categoryMove) {
+		categories = append(categories, categoryMove)
+	}
+	return categories
+}
+
+// outputEntry is a root module output change
+type outputEntry struct {
+	Name   string
+	Change plan.Change
+
+	// Sensitive is set when planned_values marks the output as sensitive
+	Sensitive bool
+}
+
+func extractResourceChanges(p *plan.Plan) []resourceEntry {
+	var changes []resourceEntry
+	var driftAddresses []string
+
+	// First, collect addresses from drift that have delete actions
+	for _, drift := range p.ResourceDrift {
+		if drift.Change.Actions.Primary() == plan.ActionDelete {
+			driftAddresses = append(driftAddresses, drift.Address)
+		}
+	}
+
+	// Then process resource changes, marking as replace if they also appear in drift
+	for _, change := range p.ResourceChanges {
+		entry := resourceEntry{ResourceChange: change}
+
+		// Filter out no-op changes, except for moves and imports which
+		// change the state without changing the object
+		actions := change.Change.Actions
+		if actions.NoOp() && !entry.isMove() && !entry.isImport() {
+			continue
+		}
+
+		// Check if this resource also appears in drift (indicating replace)
+		if contains(driftAddresses, change.Address) && actions.Primary() == plan.ActionCreate {
+			entry.IsReplace = true
+		}
+
+		// Also check if actions contain both create and delete (direct replace)
+		if actions.Replace() {
+			entry.IsReplace = true
+		}
+		changes = append(changes, entry)
+	}
+
+	return changes
+}
+
+// selectResourceChanges returns the resource changes a report lists, in the
+// order the options ask for
+func selectResourceChanges(p *plan.Plan, options reportOptions) []resourceEntry {
+	changes := extractResourceChanges(p)
+
+	if options.HideReads {
+		var kept []resourceEntry
+		for _, change := range changes {
+			if !change.isDataSourceRead() {
+				kept = append(kept, change)
+			}
+		}
+		changes = kept
+	}
+
+	sortResourceChanges(changes, options.SortBy)
+	return changes
+}
+
+// getReplacedDriftAddresses returns the addresses of resources that were
+// deleted outside of Terraform and are now being created again. These are
+// shown as replacements in Resource Changes rather than counted as drift.
+func getReplacedDriftAddresses(p *plan.Plan) []string {
+	var replaceAddresses []string
+
+	// Collect addresses that are being replaced (appear in both changes and drift)
+	for _, drift := range p.ResourceDrift {
+		if drift.Change.Actions.Primary() != plan.ActionDelete {
+			continue
+		}
+
+		// Check if this address also appears in resource_changes as create
+		for _, change := range p.ResourceChanges {
+			if change.Address == drift.Address && change.Change.Actions.Primary() == plan.ActionCreate {
+				replaceAddresses = append(replaceAddresses, drift.Address)
+				break
+			}
+		}
+	}
+
+	return replaceAddresses
+}
+
+// extractDriftChanges splits resource_drift into the drift to report and the
+// entries that are already covered by a replacement in Resource Changes.
+func extractDriftChanges(p *plan.Plan) ([]resourceEntry, []resourceEntry) {
+	var drifted []resourceEntry
+	var replaced []resourceEntry
+
+	replaceAddresses := getReplacedDriftAddresses(p)
+
+	for _, drift := range p.ResourceDrift {
+		if contains(replaceAddresses, drift.Address) {
+			replaced = append(replaced, resourceEntry{ResourceChange: drift})
+		} else {
+			drifted = append(drifted, resourceEntry{ResourceChange: drift})
+		}
+	}
+
+	return drifted, replaced
+}
+
+func countDriftChanges(p *plan.Plan) int {
+	drifted, _ := extractDriftChanges(p)
+	return len(drifted)
+}
+
+// extractOutputChanges returns every root module output change sorted by
+// name, noting whether planned_values marks the output as sensitive.
+func extractOutputChanges(p *plan.Plan) []outputEntry {
+	var outputs []outputEntry
+
+	names := make([]string, 0, len(p.OutputChanges))
+	for name := range p.OutputChanges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		output := outputEntry{Name: name, Change: p.OutputChanges[name]}
+
+		// planned_values carries the sensitive flag from the output declaration
+		if p.PlannedValues != nil {
+			if planned, ok := p.PlannedValues.Outputs[name]; ok && planned.Sensitive {
+				output.Sensitive = true
+			}
+		}
+		outputs = append(outputs, output)
+	}
+
+	return outputs
+}
+
+func countChangedOutputs(outputs []outputEntry) int {
+	count := 0
+	for _, output := range outputs {
+		if !output.Change.Actions.NoOp() {
+			count++
+		}
+	}
+	return count
+}
+
+// Helper function to check if a slice contains a string
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
+
+// summaryActions lists the actions counted in plan summaries, in the order
+// they are shown
+var summaryActions = []string{"create", "update", "replace", "delete"}
+
+// countCategories tallies resource changes by summary category. A change
+// can count towards more than one category, e.g. an update that is also
+// a move.
+func countCategories(changes []resourceEntry) map[string]int {
+	counts := make(map[string]int)
+	for _, change := range changes {
+		for _, category := range change.categories() {
+			counts[category]++
+		}
+	}
+	return counts
+}
+
+// countActions tallies resource changes by the action shown on their badge
+func countActions(changes []resourceEntry) map[string]int {
+	counts := make(map[string]int)
+	for _, change := range changes {
+		counts[change.displayActions()[0]]++
+	}
+	return counts
+}
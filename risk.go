@@ -0,0 +1,54 @@
+package main
+
+import "fmt"
+
+// statefulResourceTypes are resource types that hold data which is lost when
+// the object is destroyed, such as databases, disks and storage buckets
+var statefulResourceTypes = map[string]bool{
+	"aws_db_instance":                    true,
+	"aws_docdb_cluster":                  true,
+	"aws_dynamodb_table":                 true,
+	"aws_ebs_volume":                     true,
+	"aws_efs_file_system":                true,
+	"aws_elasticache_cluster":            true,
+	"aws_elasticache_replication_group":  true,
+	"aws_elasticsearch_domain":           true,
+	"aws_kinesis_stream":                 true,
+	"aws_msk_cluster":                    true,
+	"aws_neptune_cluster":                true,
+	"aws_opensearch_domain":              true,
+	"aws_rds_cluster":                    true,
+	"aws_rds_cluster_instance":           true,
+	"aws_redshift_cluster":               true,
+	"aws_s3_bucket":                      true,
+	"aws_sqs_queue":                      true,
+	"azurerm_cosmosdb_account":           true,
+	"azurerm_key_vault":                  true,
+	"azurerm_managed_disk":               true,
+	"azurerm_mssql_database":             true,
+	"azurerm_mysql_flexible_server":      true,
+	"azurerm_postgresql_flexible_server": true,
+	"azurerm_postgresql_server":          true,
+	"azurerm_redis_cache":                true,
+	"azurerm_storage_account":            true,
+	"google_bigtable_instance":           true,
+	"google_compute_disk":                true,
+	"google_redis_instance":              true,
+	"google_spanner_instance":            true,
+	"google_sql_database_instance":       true,
+	"google_storage_bucket":              true,
+}
+
+// isHighRiskReplace reports whether the entry destroys a stateful resource
+// before its replacement exists. Unlike create-before-destroy, there is no
+// window in which both objects exist, so data and availability are lost
+// until the new object is ready.
+func (e resourceEntry) isHighRiskReplace() bool {
+	return e.Change.Actions.DestroyBeforeCreate() && statefulResourceTypes[e.Type]
+}
+
+// highRiskReplaceWarning explains why a destroy-first replacement of a
+// stateful resource needs a closer look
+func highRiskReplaceWarning(change resourceEntry) string {
+	return fmt.Sprintf("This %s will be destroyed before its replacement is created. Any data it holds is lost unless it is backed up or restored from a snapshot, and it is unavailable until the new object is ready. Consider create_before_destroy if the resource supports it.", change.Type)
+}
@@ -0,0 +1,118 @@
+package main
+
+// sensitivePlaceholder is rendered wherever Terraform would hide a value.
+const sensitivePlaceholder = "(sensitive value)"
+
+// isSensitive reports whether a before_sensitive/after_sensitive mask marks
+// the whole value it describes as sensitive.
+func isSensitive(mask interface{}) bool {
+	sensitive, ok := mask.(bool)
+	return ok && sensitive
+}
+
+// sensitiveMaskForKey returns the part of a sensitivity mask that applies to
+// an attribute of an object. A sensitive parent makes every child sensitive.
+func sensitiveMaskForKey(mask interface{}, key string) interface{} {
+	if isSensitive(mask) {
+		return true
+	}
+	if maskMap, ok := mask.(map[string]interface{}); ok {
+		return maskMap[key]
+	}
+	return nil
+}
+
+// sensitiveMaskForIndex returns the part of a sensitivity mask that applies
+// to an element of a list, set or tuple.
+func sensitiveMaskForIndex(mask interface{}, index int) interface{} {
+	if isSensitive(mask) {
+		return true
+	}
+	if maskList, ok := mask.([]interface{}); ok && index < len(maskList) {
+		return maskList[index]
+	}
+	return nil
+}
+
+// redactSensitive returns a copy of value where every part marked as
+// sensitive by mask, at any depth, is replaced with sensitivePlaceholder.
+// The original value is left untouched.
+func redactSensitive(value, mask interface{}) interface{} {
+	return replaceSensitive(value, mask, sensitivePlaceholder)
+}
+
+// replaceSensitive is redactSensitive with a different replacement for the
+// sensitive parts
+func replaceSensitive(value, mask interface{}, replacement string) interface{} {
+	if isSensitive(mask) {
+		return replacement
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			redacted[key] = replaceSensitive(child, sensitiveMaskForKey(mask, key), replacement)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, child := range v {
+			redacted[i] = replaceSensitive(child, sensitiveMaskForIndex(mask, i), replacement)
+		}
+		return redacted
+	default:
+		return value
+	}
+}
+
+// hasSensitive reports whether a sensitivity mask marks any part of a value
+// as sensitive
+func hasSensitive(mask interface{}) bool {
+	return normalizeSensitiveMask(mask) != nil
+}
+
+// sensitiveMasksEqual reports whether two masks hide exactly the same parts
+// of a value. A value becoming (or ceasing to be) sensitive is a change even
+// when the underlying value is the same.
+func sensitiveMasksEqual(a, b interface{}) bool {
+	return valuesEqual(normalizeSensitiveMask(a), normalizeSensitiveMask(b))
+}
+
+// normalizeSensitiveMask drops the empty objects and false leaves Terraform
+// emits for non-sensitive attributes so masks can be compared structurally.
+func normalizeSensitiveMask(mask interface{}) interface{} {
+	switch m := mask.(type) {
+	case bool:
+		if m {
+			return true
+		}
+		return nil
+	case map[string]interface{}:
+		normalized := make(map[string]interface{})
+		for key, child := range m {
+			if n := normalizeSensitiveMask(child); n != nil {
+				normalized[key] = n
+			}
+		}
+		if len(normalized) == 0 {
+			return nil
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(m))
+		empty := true
+		for i, child := range m {
+			normalized[i] = normalizeSensitiveMask(child)
+			if normalized[i] != nil {
+				empty = false
+			}
+		}
+		if empty {
+			return nil
+		}
+		return normalized
+	default:
+		return nil
+	}
+}
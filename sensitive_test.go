@@ -0,0 +1,144 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"cloudvic-tf-plan-viz/plan"
+)
+
+// sensitivePlan marks values as sensitive at every depth: whole attributes,
+// attributes of nested objects, elements of lists and a JSON-encoded policy.
+// Every secret contains "topsecret".
+const sensitivePlan = `{
+  "format_version": "1.2",
+  "resource_changes": [
+    {
+      "address": "aws_db_instance.main",
+      "mode": "managed",
+      "type": "aws_db_instance",
+      "name": "main",
+      "provider_name": "registry.terraform.io/hashicorp/aws",
+      "change": {
+        "actions": ["update"],
+        "before": {
+          "instance_class": "db.t3.small",
+          "password": "topsecret-password-1",
+          "connection": {"host": "db.internal", "token": "topsecret-token-1"},
+          "users": [{"name": "app", "password": "topsecret-user-1"}],
+          "policy": "{\"Statement\": [{\"Effect\": \"Allow\", \"Action\": \"topsecret:Read\", \"Resource\": \"*\"}]}"
+        },
+        "after": {
+          "instance_class": "db.t3.large",
+          "password": "topsecret-password-2",
+          "connection": {"host": "db.internal", "token": "topsecret-token-2"},
+          "users": [{"name": "app", "password": "topsecret-user-2"}],
+          "policy": "{\"Statement\": [{\"Effect\": \"Allow\", \"Action\": \"topsecret:Write\", \"Resource\": \"*\"}]}"
+        },
+        "after_unknown": {},
+        "before_sensitive": {"password": true, "connection": {"token": true}, "users": [{"password": true}], "policy": true},
+        "after_sensitive": {"password": true, "connection": {"token": true}, "users": [{"password": true}], "policy": true}
+      }
+    },
+    {
+      "address": "aws_ssm_parameter.created",
+      "mode": "managed",
+      "type": "aws_ssm_parameter",
+      "name": "created",
+      "provider_name": "registry.terraform.io/hashicorp/aws",
+      "change": {
+        "actions": ["create"],
+        "before": null,
+        "after": {"name": "/app/key", "value": "topsecret-created", "tags": {"owner": "topsecret-owner"}},
+        "after_unknown": {},
+        "after_sensitive": {"value": true, "tags": {"owner": true}}
+      }
+    },
+    {
+      "address": "aws_ssm_parameter.deleted",
+      "mode": "managed",
+      "type": "aws_ssm_parameter",
+      "name": "deleted",
+      "provider_name": "registry.terraform.io/hashicorp/aws",
+      "change": {
+        "actions": ["delete"],
+        "before": {"name": "/app/old", "value": "topsecret-deleted"},
+        "after": null,
+        "before_sensitive": {"value": true}
+      }
+    }
+  ],
+  "resource_drift": [
+    {
+      "address": "aws_db_instance.main",
+      "mode": "managed",
+      "type": "aws_db_instance",
+      "name": "main",
+      "provider_name": "registry.terraform.io/hashicorp/aws",
+      "change": {
+        "actions": ["update"],
+        "before": {"instance_class": "db.t3.micro", "password": "topsecret-drift-1"},
+        "after": {"instance_class": "db.t3.small", "password": "topsecret-drift-2"},
+        "before_sensitive": {"password": true},
+        "after_sensitive": {"password": true}
+      }
+    }
+  ],
+  "output_changes": {
+    "connection": {
+      "actions": ["update"],
+      "before": {"user": "app", "password": "topsecret-output-1"},
+      "after": {"user": "app", "password": "topsecret-output-2"},
+      "before_sensitive": {"password": true},
+      "after_sensitive": {"password": true}
+    },
+    "password": {
+      "actions": ["create"],
+      "before": null,
+      "after": "topsecret-output-3",
+      "after_sensitive": true
+    }
+  }
+}`
+
+func TestSensitiveValuesAreMasked(t *testing.T) {
+	planData, err := plan.Parse([]byte(sensitivePlan))
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := reportOptions{SortBy: sortByAddress}
+
+	tests := []struct {
+		name     string
+		output   string
+		contains []string
+	}{
+		{
+			name:     "html",
+			output:   generateHtml(planData, options),
+			contains: []string{sensitivePlaceholder, "sensitive value changed", "db.t3.large"},
+		},
+		{
+			name:     "markdown",
+			output:   generateMarkdown(planData, options),
+			contains: []string{`~ password = ` + sensitivePlaceholder, `+ value = ` + sensitivePlaceholder, `~ policy = ` + sensitivePlaceholder, "db.t3.large"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if i := strings.Index(tt.output, "topsecret"); i >= 0 {
+				end := i + 40
+				if end > len(tt.output) {
+					end = len(tt.output)
+				}
+				t.Errorf("output contains a sensitive value: %q", tt.output[i:end])
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(tt.output, want) {
+					t.Errorf("output does not contain %q", want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// textSegment is a run of text that is either shared by both sides of a
+// change or only present on one of them. Sensitive and Unknown mark the
+// placeholders inside an output value.
+type textSegment struct {
+	Text      string
+	Changed   bool
+	Sensitive bool
+	Unknown   bool
+}
+
+// inlineDiffLimit caps the size of the LCS table of an inline diff. Beyond
+// it, only the common prefix and suffix of the two values are trimmed.
+const inlineDiffLimit = 4000000
+
+// inlineDiffMinMatch is the shortest run of shared characters kept between
+// two changes. Shorter runs are highlighted along with the changes around
+// them, so random strings such as keys do not break up into single letters.
+const inlineDiffMinMatch = 4
+
+// inlineDiff splits two versions of a value into the text they share and
+// the parts that differ, using the longest common subsequence of their
+// characters. This pinpoints the changes in long values such as ARNs or
+// certificates, where a difference far into the string is otherwise easy to
+// miss.
+func inlineDiff(before, after string) ([]textSegment, []textSegment) {
+	a, b := []rune(before), []rune(after)
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits, ok := diffSequences(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], inlineDiffLimit)
+	if !ok {
+		return splitSegments(a, prefix, suffix), splitSegments(b, prefix, suffix)
+	}
+	edits = absorbShortMatches(edits, inlineDiffMinMatch)
+
+	beforeSegments := appendSegment(nil, a[:prefix], false)
+	afterSegments := appendSegment(nil, b[:prefix], false)
+	i, j := prefix, prefix
+	for _, kind := range edits {
+		switch kind {
+		case diffUnchanged:
+			beforeSegments = appendSegment(beforeSegments, a[i:i+1], false)
+			afterSegments = appendSegment(afterSegments, b[j:j+1], false)
+			i++
+			j++
+		case diffRemoved:
+			beforeSegments = appendSegment(beforeSegments, a[i:i+1], true)
+			i++
+		case diffAdded:
+			afterSegments = appendSegment(afterSegments, b[j:j+1], true)
+			j++
+		}
+	}
+	beforeSegments = appendSegment(beforeSegments, a[len(a)-suffix:], false)
+	afterSegments = appendSegment(afterSegments, b[len(b)-suffix:], false)
+
+	return beforeSegments, afterSegments
+}
+
+// absorbShortMatches turns runs of fewer than minMatch unchanged elements
+// into a removal and an addition, merging them into the changes around them
+func absorbShortMatches(edits []diffKind, minMatch int) []diffKind {
+	var result []diffKind
+	for start := 0; start < len(edits); {
+		end := start + 1
+		for end < len(edits) && (edits[end] == diffUnchanged) == (edits[start] == diffUnchanged) {
+			end++
+		}
+
+		// Runs at either end border the unchanged prefix or suffix, so
+		// only runs between two changes are absorbed
+		if edits[start] == diffUnchanged && end-start < minMatch && start > 0 && end < len(edits) {
+			for k := start; k < end; k++ {
+				result = append(result, diffRemoved)
+			}
+			for k := start; k < end; k++ {
+				result = append(result, diffAdded)
+			}
+		} else {
+			result = append(result, edits[start:end]...)
+		}
+		start = end
+	}
+	return result
+}
+
+// appendSegment adds text to the end of segments, extending the last
+// segment when it has the same kind
+func appendSegment(segments []textSegment, text []rune, changed bool) []textSegment {
+	if len(text) == 0 {
+		return segments
+	}
+	if last := len(segments) - 1; last >= 0 && segments[last].Changed == changed {
+		segments[last].Text += string(text)
+		return segments
+	}
+	return append(segments, textSegment{Text: string(text), Changed: changed})
+}
+
+// splitSegments cuts text into an unchanged prefix, a changed middle and an
+// unchanged suffix, leaving out empty parts
+func splitSegments(text []rune, prefix, suffix int) []textSegment {
+	var segments []textSegment
+	parts := []textSegment{
+		{Text: string(text[:prefix])},
+		{Text: string(text[prefix : len(text)-suffix]), Changed: true},
+		{Text: string(text[len(text)-suffix:])},
+	}
+	for _, part := range parts {
+		if part.Text != "" {
+			segments = append(segments, part)
+		}
+	}
+	return segments
+}
+
+// Lines of unchanged context kept around each change in a line diff
+const lineDiffContext = 3
+
+// lineDiffLimit caps the size of the LCS table. Beyond it, values are shown
+// as wholly removed and added rather than spending seconds on the diff.
+const lineDiffLimit = 4000000
+
+// textDiffLine is one row of a unified line diff. A row with Skipped set
+// stands for that many unchanged lines left out between hunks.
+type textDiffLine struct {
+	Kind    diffKind
+	Text    string
+	Skipped int
+}
+
+// unifiedLineDiff compares two multi-line values line by line, using the
+// longest common subsequence of their lines, and keeps only a few lines of
+// context around each change, like diff -u.
+func unifiedLineDiff(before, after string) []textDiffLine {
+	lines := diffTextLines(strings.Split(before, "\n"), strings.Split(after, "\n"))
+
+	// Mark the unchanged lines close enough to a change to be kept
+	keep := make([]bool, len(lines))
+	for i, line := range lines {
+		if line.Kind == diffUnchanged {
+			continue
+		}
+		for j := i - lineDiffContext; j <= i+lineDiffContext; j++ {
+			if j >= 0 && j < len(lines) {
+				keep[j] = true
+			}
+		}
+	}
+
+	var result []textDiffLine
+	skipped := 0
+	for i, line := range lines {
+		if keep[i] {
+			if skipped > 0 {
+				result = append(result, textDiffLine{Kind: diffUnchanged, Skipped: skipped})
+				skipped = 0
+			}
+			result = append(result, line)
+			continue
+		}
+		skipped++
+	}
+	if skipped > 0 {
+		result = append(result, textDiffLine{Kind: diffUnchanged, Skipped: skipped})
+	}
+
+	return result
+}
+
+// diffTextLines returns the edit script turning a into b, with removals
+// listed before additions wherever the two sides differ
+func diffTextLines(a, b []string) []textDiffLine {
+	var lines []textDiffLine
+
+	edits, ok := diffSequences(a, b, lineDiffLimit)
+	if !ok {
+		for _, line := range a {
+			lines = append(lines, textDiffLine{Kind: diffRemoved, Text: line})
+		}
+		for _, line := range b {
+			lines = append(lines, textDiffLine{Kind: diffAdded, Text: line})
+		}
+		return lines
+	}
+
+	i, j := 0, 0
+	for _, kind := range edits {
+		switch kind {
+		case diffUnchanged:
+			lines = append(lines, textDiffLine{Kind: diffUnchanged, Text: a[i]})
+			i++
+			j++
+		case diffRemoved:
+			lines = append(lines, textDiffLine{Kind: diffRemoved, Text: a[i]})
+			i++
+		case diffAdded:
+			lines = append(lines, textDiffLine{Kind: diffAdded, Text: b[j]})
+			j++
+		}
+	}
+
+	return lines
+}
+
+// diffSequences returns the edit script turning a into b, found with the
+// longest common subsequence: one entry per element, saying whether it is
+// kept, removed from a or added from b. Removals are listed before additions
+// wherever the two sides differ. It gives up when the LCS table would have
+// more than limit cells.
+func diffSequences[T comparable](a, b []T, limit int) ([]diffKind, bool) {
+	if len(a)*len(b) > limit {
+		return nil, false
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var edits []diffKind
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			edits = append(edits, diffUnchanged)
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, diffRemoved)
+			i++
+		default:
+			edits = append(edits, diffAdded)
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		edits = append(edits, diffRemoved)
+	}
+	for ; j < len(b); j++ {
+		edits = append(edits, diffAdded)
+	}
+
+	return edits, true
+}
+
+// marker returns the gutter symbol for a line diff row
+func (l textDiffLine) marker() string {
+	return diffLine{Kind: l.Kind}.marker()
+}
+
+// skippedComment describes the unchanged lines a Skipped row stands for
+func (l textDiffLine) skippedComment() string {
+	if l.Skipped == 1 {
+		return "# (1 unchanged line hidden)"
+	}
+	return fmt.Sprintf("# (%d unchanged lines hidden)", l.Skipped)
+}
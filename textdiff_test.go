@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns n lines "line 1" to "line n", with the given lines
+// replaced
+func numberedLines(n int, replace map[int]string) string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i+1)
+		if text, ok := replace[i+1]; ok {
+			lines[i] = text
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatTextDiffLines renders a line diff compactly: "@N" for N skipped
+// lines, otherwise the marker followed by the text
+func formatTextDiffLines(lines []textDiffLine) []string {
+	var rows []string
+	for _, line := range lines {
+		if line.Skipped > 0 {
+			rows = append(rows, fmt.Sprintf("@%d", line.Skipped))
+			continue
+		}
+		rows = append(rows, line.marker()+line.Text)
+	}
+	return rows
+}
+
+func TestUnifiedLineDiff(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		want          []string
+	}{
+		{
+			name:   "one changed line in a large block",
+			before: numberedLines(100, nil),
+			after:  numberedLines(100, map[int]string{50: "changed"}),
+			want: []string{"@46",
+				" line 47", " line 48", " line 49", "-line 50", "+changed", " line 51", " line 52", " line 53",
+				"@47"},
+		},
+		{
+			name:   "changes far apart get a hunk each",
+			before: numberedLines(30, nil),
+			after:  numberedLines(30, map[int]string{5: "first", 25: "second"}),
+			want: []string{"@1",
+				" line 2", " line 3", " line 4", "-line 5", "+first", " line 6", " line 7", " line 8",
+				"@13",
+				" line 22", " line 23", " line 24", "-line 25", "+second", " line 26", " line 27", " line 28",
+				"@2"},
+		},
+		{
+			name:   "changes with overlapping context share a hunk",
+			before: numberedLines(20, nil),
+			after:  numberedLines(20, map[int]string{8: "first", 13: "second"}),
+			want: []string{"@4",
+				" line 5", " line 6", " line 7", "-line 8", "+first",
+				" line 9", " line 10", " line 11", " line 12", "-line 13", "+second", " line 14", " line 15", " line 16",
+				"@4"},
+		},
+		{
+			name:   "lines added at the start",
+			before: "b\nc\nd\ne\nf",
+			after:  "a\nb\nc\nd\ne\nf",
+			want:   []string{"+a", " b", " c", " d", "@2"},
+		},
+		{
+			name:   "lines removed at the end",
+			before: "a\nb\nc",
+			after:  "a\nb",
+			want:   []string{" a", " b", "-c"},
+		},
+		{
+			name:   "identical values",
+			before: "a\nb",
+			after:  "a\nb",
+			want:   []string{"@2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatTextDiffLines(unifiedLineDiff(tt.before, tt.after))
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("unifiedLineDiff() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestDiffTextLinesLimit(t *testing.T) {
+	// Past the limit the values are not compared at all: every old line is
+	// removed and every new line added
+	const n = 2001
+	if n*n <= lineDiffLimit {
+		t.Fatalf("%d lines per side do not exceed the limit of %d", n, lineDiffLimit)
+	}
+	before := strings.Split(numberedLines(n, nil), "\n")
+	after := strings.Split(numberedLines(n, map[int]string{1000: "changed"}), "\n")
+
+	lines := diffTextLines(before, after)
+	if len(lines) != 2*n {
+		t.Fatalf("got %d lines, want %d", len(lines), 2*n)
+	}
+	for i, line := range lines {
+		want := diffRemoved
+		if i >= n {
+			want = diffAdded
+		}
+		if line.Kind != want {
+			t.Fatalf("line %d is %s, want %s", i, line.Kind, want)
+		}
+	}
+
+	// Just under the limit the same change is a single replaced line
+	lines = diffTextLines(before[:1999], after[:1999])
+	changed := 0
+	for _, line := range lines {
+		if line.Kind != diffUnchanged {
+			changed++
+		}
+	}
+	if changed != 2 {
+		t.Errorf("got %d changed lines under the limit, want 2", changed)
+	}
+}
+
+// formatSegments renders inline diff segments with the changed parts in
+// brackets
+func formatSegments(segments []textSegment) string {
+	var text strings.Builder
+	for _, segment := range segments {
+		if segment.Changed {
+			text.WriteString("[" + segment.Text + "]")
+		} else {
+			text.WriteString(segment.Text)
+		}
+	}
+	return text.String()
+}
+
+func TestInlineDiff(t *testing.T) {
+	key := "MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAu1SU1LfVLPHCozMxH2Mo4lgOEePzNm0tRgeLezV6ffAt0gunVTLw7onLRnrq"
+	long := strings.Repeat("abcdefghij", 210)
+
+	tests := []struct {
+		name                  string
+		before, after         string
+		wantBefore, wantAfter string
+	}{
+		{
+			name:       "two edits far apart",
+			before:     "arn:aws:iam::111111111111:role/service-role/app-reader-prod",
+			after:      "arn:aws:iam::222222222222:role/service-role/app-writer-prod",
+			wantBefore: "arn:aws:iam::[111111111111]:role/service-role/app-[read]er-prod",
+			wantAfter:  "arn:aws:iam::[222222222222]:role/service-role/app-[writ]er-prod",
+		},
+		{
+			name:       "edits at both ends of a key",
+			before:     "u1" + key + "0",
+			after:      "x9" + key + "Z",
+			wantBefore: "[u1]" + key + "[0]",
+			wantAfter:  "[x9]" + key + "[Z]",
+		},
+		{
+			name:       "short matches are not highlighted on their own",
+			before:     "k7Qz9pLm2Xw",
+			after:      "R4tY8nBv1Cs",
+			wantBefore: "[k7Qz9pLm2Xw]",
+			wantAfter:  "[R4tY8nBv1Cs]",
+		},
+		{
+			name:       "added to an empty value",
+			before:     "",
+			after:      "new",
+			wantBefore: "",
+			wantAfter:  "[new]",
+		},
+		{
+			name:       "past the limit only the prefix and suffix are trimmed",
+			before:     "a" + long + "b",
+			after:      "c" + long + "d",
+			wantBefore: "[a" + long + "b]",
+			wantAfter:  "[c" + long + "d]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after := inlineDiff(tt.before, tt.after)
+			if got := formatSegments(before); got != tt.wantBefore {
+				t.Errorf("before = %q, want %q", got, tt.wantBefore)
+			}
+			if got := formatSegments(after); got != tt.wantAfter {
+				t.Errorf("after = %q, want %q", got, tt.wantAfter)
+			}
+		})
+	}
+}